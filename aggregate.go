@@ -0,0 +1,466 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// NewAggregateReflector constructs a Reflector that merges several sources of
+// reflection information into one. It lists the services of every source, and
+// it answers requests for a file, symbol, or extension using the first source
+// that knows about it. Sources may be any Reflector, including local ones built
+// with NewStaticReflector or NewReflector and remote ones built with
+// NewProxyReflector.
+//
+// Sources sometimes disagree about the contents of a file: two backends may
+// have been built with different versions of a shared dependency, for example.
+// Whenever a response would include a file, the aggregate checks whether any
+// other source defines a file with the same path but different contents. If
+// so, it consults the ConflictPolicy; a nil policy is equivalent to
+// PreferFirstSource.
+//
+// A file is always served along with its imports from the same source, so
+// that the files a client receives in one response are consistent with each
+// other. Since each file is only sent once per stream, a client that asks for
+// symbols from two sources that disagree about a shared import keeps the copy
+// it received first. Use FailOnConflict if sources must agree.
+//
+// Sources keep the options that control which files they serve and how those
+// files are sent: a source built with WithServiceFilesOnly only contributes
+// the files it allows, and files are converted with the source's
+// WithSourceInfo, WithDownleveledEditions, and scrubbing options before the
+// aggregate's own. Options that apply to whole streams, like limits, host
+// routing, dependency handling, and observers, only take effect on the
+// aggregate.
+//
+// Any supplied options are applied after the aggregate's own resolvers are
+// configured, so they may override them.
+func NewAggregateReflector(sources []*Reflector, policy ConflictPolicy, options ...Option) *Reflector {
+	if policy == nil {
+		policy = PreferFirstSource
+	}
+	aggregate := &aggregateResolver{
+		sources: sources,
+		policy:  policy,
+		owners:  make(map[string][]fileOwner),
+	}
+	aggregateOptions := []Option{
+		WithDescriptorResolver(aggregate),
+		WithExtensionResolver(aggregate),
+	}
+	reflector := NewReflector(aggregate, append(aggregateOptions, options...)...)
+	reflector.aggregate = aggregate
+	return reflector
+}
+
+// A ConflictPolicy decides how an aggregate Reflector handles two sources that
+// disagree about the contents of a file. If the policy returns nil, the file is
+// served from the source that answered the request: the first source, in
+// order, that has the requested file, symbol, or extension. Otherwise, the
+// request that led to the conflict fails with the returned error.
+//
+// ConflictPolicies must be safe to call concurrently.
+type ConflictPolicy func(*FileConflictError) error
+
+// PreferFirstSource is a ConflictPolicy that ignores conflicts. Files are
+// served from the first source that has the requested element, and their
+// imports come from the same source, even if an earlier source has a different
+// copy of an import.
+func PreferFirstSource(*FileConflictError) error {
+	return nil
+}
+
+// FailOnConflict is a ConflictPolicy that fails any request whose response
+// would include a conflicting file.
+func FailOnConflict(conflict *FileConflictError) error {
+	return conflict
+}
+
+// LogConflicts returns a ConflictPolicy that logs conflicts at warning level
+// and then ignores them, like PreferFirstSource.
+func LogConflicts(logger *slog.Logger) ConflictPolicy {
+	return func(conflict *FileConflictError) error {
+		logger.Warn(
+			"conflicting file descriptors in aggregate reflector",
+			slog.String("path", conflict.Path),
+			slog.Int("first_source", conflict.First),
+			slog.Int("second_source", conflict.Second),
+		)
+		return nil
+	}
+}
+
+// FileConflictError describes two sources of an aggregate Reflector that
+// define different files with the same path. Sources are identified by their
+// index in the slice passed to NewAggregateReflector.
+type FileConflictError struct {
+	Path   string
+	First  int
+	Second int
+}
+
+func (e *FileConflictError) Error() string {
+	return fmt.Sprintf("sources %d and %d have conflicting definitions of %q", e.First, e.Second, e.Path)
+}
+
+// aggregateResolver implements Namer, protodesc.Resolver, and ExtensionResolver
+// (along with their context-aware counterparts) by consulting each source in
+// order.
+type aggregateResolver struct {
+	sources []*Reflector
+	policy  ConflictPolicy
+
+	// owners records which source each file was served from, so that it can
+	// be converted with that source's options. It's keyed by path and holds
+	// at most one entry per source.
+	ownersMu sync.Mutex
+	owners   map[string][]fileOwner
+}
+
+type fileOwner struct {
+	file   protoreflect.FileDescriptor
+	source *Reflector
+}
+
+func (a *aggregateResolver) Names() []string {
+	names, _ := a.NamesContext(context.Background())
+	return names
+}
+
+// NamesContext merges the service names of all sources. Sources that fail are
+// skipped, so that one unavailable backend doesn't hide the services of all the
+// others; an error is only returned if every source fails.
+func (a *aggregateResolver) NamesContext(ctx context.Context) ([]string, error) {
+	var names []string
+	var firstErr error
+	failures := 0
+	seen := make(map[string]struct{})
+	for _, source := range a.sources {
+		sourceNames, err := namesContext(ctx, source.namer)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failures++
+			continue
+		}
+		for _, name := range sourceNames {
+			if _, ok := seen[name]; ok {
+				continue
+			}
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	if failures > 0 && failures == len(a.sources) {
+		return nil, firstErr
+	}
+	return names, nil
+}
+
+func (a *aggregateResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return a.FindFileByPathContext(context.Background(), path)
+}
+
+func (a *aggregateResolver) FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error) {
+	return findInSources(ctx, a, func(source *Reflector) (protoreflect.FileDescriptor, error) {
+		return findFileByPath(ctx, source.descriptorResolver, path)
+	}, func(file protoreflect.FileDescriptor) protoreflect.FileDescriptor {
+		return file
+	})
+}
+
+func (a *aggregateResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return a.FindDescriptorByNameContext(context.Background(), name)
+}
+
+func (a *aggregateResolver) FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return findInSources(ctx, a, func(source *Reflector) (protoreflect.Descriptor, error) {
+		return findDescriptorByName(ctx, source.descriptorResolver, name)
+	}, protoreflect.Descriptor.ParentFile)
+}
+
+func (a *aggregateResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return findInSources(context.Background(), a, func(source *Reflector) (protoreflect.ExtensionType, error) {
+		return source.extensionResolver.FindExtensionByName(field)
+	}, extensionFile)
+}
+
+func (a *aggregateResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return a.FindExtensionByNumberContext(context.Background(), message, field)
+}
+
+func (a *aggregateResolver) FindExtensionByNumberContext(ctx context.Context, message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return findInSources(ctx, a, func(source *Reflector) (protoreflect.ExtensionType, error) {
+		return findExtensionByNumber(ctx, source.extensionResolver, message, field)
+	}, extensionFile)
+}
+
+func (a *aggregateResolver) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	a.RangeExtensionsByMessageContext(context.Background(), message, f)
+}
+
+func (a *aggregateResolver) RangeExtensionsByMessageContext(ctx context.Context, message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	seen := make(map[protoreflect.FieldNumber]struct{})
+	for i, source := range a.sources {
+		keepGoing := true
+		rangeExtensionsByMessage(ctx, source.extensionResolver, message, func(ext protoreflect.ExtensionType) bool {
+			number := ext.TypeDescriptor().Number()
+			if _, ok := seen[number]; ok {
+				return true
+			}
+			if allowed, err := a.allowed(ctx, i, extensionFile(ext)); err != nil || !allowed {
+				return true
+			}
+			seen[number] = struct{}{}
+			keepGoing = f(ext)
+			return keepGoing
+		})
+		if !keepGoing {
+			return
+		}
+	}
+}
+
+// checkConflicts compares every file in the transitive closure of the given
+// file, which came from the source at index owner, with the files of the same
+// path in all other sources. Each file is only compared once per reflection
+// stream, since its closure is usually shared by many requests.
+func (a *aggregateResolver) checkConflicts(ctx context.Context, file protoreflect.FileDescriptor, owner int) error {
+	session := a.session(ctx)
+	seen := make(map[string]struct{})
+	queue := []protoreflect.FileDescriptor{file}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if _, ok := seen[curr.Path()]; ok || curr.IsPlaceholder() {
+			continue
+		}
+		seen[curr.Path()] = struct{}{}
+		a.own(curr, a.sources[owner])
+		key := conflictCheck{path: curr.Path(), owner: owner}
+		checked, err := session.checked(key)
+		if !checked {
+			err = a.checkFileConflicts(ctx, curr, owner)
+			session.check(key, err)
+		}
+		if err != nil {
+			return err
+		}
+		imports := curr.Imports()
+		for i := range imports.Len() {
+			queue = append(queue, imports.Get(i).FileDescriptor)
+		}
+	}
+	return nil
+}
+
+// checkFileConflicts compares the file, which came from the source at index
+// owner, with the files of the same path in all other sources.
+func (a *aggregateResolver) checkFileConflicts(ctx context.Context, file protoreflect.FileDescriptor, owner int) error {
+	for i, source := range a.sources {
+		if i == owner {
+			continue
+		}
+		other, err := findFileByPath(ctx, source.descriptorResolver, file.Path())
+		if err != nil || other.IsPlaceholder() || sameFile(file, other) {
+			continue
+		}
+		if allowed, err := a.allowed(ctx, i, other); err != nil {
+			return err
+		} else if !allowed {
+			continue
+		}
+		if err := a.policy(&FileConflictError{Path: file.Path(), First: owner, Second: i}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// session returns the state the resolver keeps for the current reflection
+// stream. Outside of a stream, it returns new state.
+func (a *aggregateResolver) session(ctx context.Context) *aggregateSession {
+	session, _ := pinToSession(ctx, aggregateSessionKey{a}, func() any {
+		return &aggregateSession{
+			conflicts: make(map[conflictCheck]error),
+			allowed:   make(map[int]map[string]struct{}),
+		}
+	}).(*aggregateSession)
+	return session
+}
+
+type aggregateSessionKey struct {
+	resolver *aggregateResolver
+}
+
+// aggregateSession holds the results of conflict checks, along with the files
+// allowed by sources that restrict them, for a reflection stream. Results are
+// computed without holding the lock, since sources may make network calls.
+type aggregateSession struct {
+	mu        sync.Mutex
+	conflicts map[conflictCheck]error
+	allowed   map[int]map[string]struct{}
+}
+
+type conflictCheck struct {
+	path  string
+	owner int
+}
+
+// checked reports whether the file has already been checked, and the result
+// of the check.
+func (s *aggregateSession) checked(key conflictCheck) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err, ok := s.conflicts[key]
+	return ok, err
+}
+
+func (s *aggregateSession) check(key conflictCheck, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conflicts[key] = err
+}
+
+// allowed reports whether the source at the given index serves the file. Only
+// sources built with WithServiceFilesOnly restrict the files they serve.
+func (a *aggregateResolver) allowed(ctx context.Context, index int, file protoreflect.FileDescriptor) (bool, error) {
+	source := a.sources[index]
+	if !source.restrictFiles {
+		return true, nil
+	}
+	session := a.session(ctx)
+	session.mu.Lock()
+	allowed, ok := session.allowed[index]
+	session.mu.Unlock()
+	if !ok {
+		var err error
+		if allowed, err = source.computeAllowedFiles(ctx); err != nil {
+			return false, err
+		}
+		session.mu.Lock()
+		session.allowed[index] = allowed
+		session.mu.Unlock()
+	}
+	_, ok = allowed[file.Path()]
+	return ok, nil
+}
+
+// own records that the file was served from the given source. If several
+// sources serve the same descriptor, the first one to serve it keeps it.
+func (a *aggregateResolver) own(file protoreflect.FileDescriptor, source *Reflector) {
+	a.ownersMu.Lock()
+	defer a.ownersMu.Unlock()
+	owners := a.owners[file.Path()]
+	for i, owner := range owners {
+		if owner.file == file {
+			return
+		}
+		if owner.source == source {
+			// The source's schema has changed.
+			owners[i].file = file
+			return
+		}
+	}
+	a.owners[file.Path()] = append(owners, fileOwner{file: file, source: source})
+}
+
+// owner returns the source the file was served from, or nil if it's unknown.
+// It's safe to call on a nil resolver.
+func (a *aggregateResolver) owner(file protoreflect.FileDescriptor) *Reflector {
+	if a == nil {
+		return nil
+	}
+	a.ownersMu.Lock()
+	defer a.ownersMu.Unlock()
+	for _, owner := range a.owners[file.Path()] {
+		if owner.file == file {
+			return owner.source
+		}
+	}
+	return nil
+}
+
+// findInSources returns the result of find for the first source that doesn't
+// return protoregistry.NotFound, after checking for conflicts with the other
+// sources. Results from files that a source doesn't allow are treated as
+// protoregistry.NotFound. If every source fails, it returns the first error
+// other than protoregistry.NotFound, if any.
+func findInSources[T any](
+	ctx context.Context,
+	a *aggregateResolver,
+	find func(*Reflector) (T, error),
+	file func(T) protoreflect.FileDescriptor,
+) (T, error) {
+	var zero T
+	var firstErr error
+	for i, source := range a.sources {
+		result, err := find(source)
+		if errors.Is(err, protoregistry.NotFound) {
+			continue
+		} else if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if fd := file(result); fd != nil {
+			if allowed, err := a.allowed(ctx, i, fd); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			} else if !allowed {
+				continue
+			}
+			if err := a.checkConflicts(ctx, fd, i); err != nil {
+				return zero, err
+			}
+		}
+		return result, nil
+	}
+	if firstErr != nil {
+		return zero, firstErr
+	}
+	return zero, protoregistry.NotFound
+}
+
+func extensionFile(ext protoreflect.ExtensionType) protoreflect.FileDescriptor {
+	return ext.TypeDescriptor().ParentFile()
+}
+
+// sameFile reports whether two file descriptors have the same contents,
+// ignoring source code info (which only holds comments and locations).
+func sameFile(left, right protoreflect.FileDescriptor) bool {
+	if isCacheable(left) && isCacheable(right) && left == right {
+		return true
+	}
+	leftProto := protodesc.ToFileDescriptorProto(left)
+	rightProto := protodesc.ToFileDescriptorProto(right)
+	leftProto.SourceCodeInfo = nil
+	rightProto.SourceCodeInfo = nil
+	return proto.Equal(leftProto, rightProto)
+}
@@ -0,0 +1,245 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"reflect"
+	"strings"
+	"testing"
+
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestAggregateReflector(t *testing.T) {
+	t.Parallel()
+	t.Run("single_source", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector([]*Reflector{NewStaticReflector(actualServiceName)}, nil)
+		testReflector(t, reflector, serviceURLPathV1)
+	})
+
+	const (
+		testServiceName  = "connect.reflecttest.v1.TestService"
+		otherServiceName = "other.v1.OtherService"
+		reflecttestPath  = "connect/reflecttest/v1/reflecttest.proto"
+	)
+	// The second source has its own copy of reflecttest.proto, with an extra
+	// message, and a file that only it knows about.
+	reflecttestFile, err := protoregistry.GlobalFiles.FindFileByPath(reflecttestPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	modifiedProto := protodesc.ToFileDescriptorProto(reflecttestFile)
+	modifiedProto.MessageType = append(modifiedProto.MessageType, &descriptorpb.DescriptorProto{
+		Name: proto.String("Extra"),
+	})
+	otherProto := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("other/v1/other.proto"),
+		Package:    proto.String("other.v1"),
+		Dependency: []string{reflecttestPath},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("OtherService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Do"),
+				InputType:  proto.String(".connect.reflecttest.v1.DoRequest"),
+				OutputType: proto.String(".connect.reflecttest.v1.DoResponse"),
+			}},
+		}},
+	}
+	otherFiles, err := protodesc.NewFiles(&descriptorpb.FileDescriptorSet{
+		File: []*descriptorpb.FileDescriptorProto{modifiedProto, otherProto},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	newSources := func() []*Reflector {
+		return []*Reflector{
+			NewStaticReflector(actualServiceName, testServiceName),
+			NewReflector(
+				&staticNames{names: []string{testServiceName, otherServiceName}},
+				WithDescriptorResolver(otherFiles),
+			),
+		}
+	}
+
+	t.Run("names", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector(newSources(), nil)
+		expected := []string{actualServiceName, testServiceName, otherServiceName}
+		if names := reflector.namer.Names(); !reflect.DeepEqual(expected, names) {
+			t.Fatalf("unexpected names: want %v ; got %v", expected, names)
+		}
+	})
+	t.Run("routing", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector(newSources(), nil)
+		desc, err := reflector.descriptorResolver.FindDescriptorByName(otherServiceName)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if desc.ParentFile().Path() != "other/v1/other.proto" {
+			t.Fatalf("unexpected file: %s", desc.ParentFile().Path())
+		}
+		_, err = reflector.descriptorResolver.FindDescriptorByName("foo.bar.Baz")
+		if !errors.Is(err, protoregistry.NotFound) {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+	t.Run("prefer_first", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector(newSources(), PreferFirstSource)
+		desc, err := reflector.descriptorResolver.FindDescriptorByName(testServiceName)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if desc.ParentFile() != reflecttestFile {
+			t.Fatal("expected file from first source")
+		}
+		// Imports come from the source that has the requested symbol, even
+		// if an earlier source has a different copy.
+		desc, err = reflector.descriptorResolver.FindDescriptorByName(otherServiceName)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if imported := desc.ParentFile().Imports().Get(0); imported.Messages().ByName("Extra") == nil {
+			t.Fatal("expected import from second source")
+		}
+	})
+	t.Run("fail_on_conflict", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector(newSources(), FailOnConflict)
+		_, err := reflector.descriptorResolver.FindDescriptorByName(testServiceName)
+		var conflictErr *FileConflictError
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+		expected := &FileConflictError{Path: reflecttestPath, First: 0, Second: 1}
+		if !reflect.DeepEqual(expected, conflictErr) {
+			t.Fatalf("unexpected conflict: want %v ; got %v", expected, conflictErr)
+		}
+		// The conflict is in a dependency, so it's detected here too.
+		_, err = reflector.descriptorResolver.FindDescriptorByName(otherServiceName)
+		if !errors.As(err, &conflictErr) {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+		// Files without conflicts are still served.
+		if _, err := reflector.descriptorResolver.FindDescriptorByName(actualServiceName); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+	t.Run("log_conflicts", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		reflector := NewAggregateReflector(newSources(), LogConflicts(logger))
+		if _, err := reflector.descriptorResolver.FindFileByPath(reflecttestPath); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !strings.Contains(buf.String(), reflecttestPath) {
+			t.Fatalf("expected conflict to be logged, got %q", buf.String())
+		}
+	})
+	t.Run("checked_once_per_stream", func(t *testing.T) {
+		t.Parallel()
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewTextHandler(&buf, nil))
+		reflector := NewAggregateReflector(newSources(), LogConflicts(logger))
+		resolver, _ := reflector.descriptorResolver.(DescriptorResolverContext)
+		ctx := newSessionContext(t.Context(), newReflectionSession())
+		for range 2 {
+			if _, err := resolver.FindDescriptorByNameContext(ctx, testServiceName); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if _, err := resolver.FindFileByPathContext(ctx, reflecttestPath); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+		}
+		if count := strings.Count(buf.String(), reflecttestPath); count != 1 {
+			t.Fatalf("expected conflict to be logged once, got %q", buf.String())
+		}
+	})
+	t.Run("restricted_source", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector([]*Reflector{
+			NewReflector(&staticNames{names: []string{actualServiceName}}, WithServiceFilesOnly()),
+			NewReflector(
+				&staticNames{names: []string{otherServiceName}},
+				WithDescriptorResolver(otherFiles),
+			),
+		}, FailOnConflict)
+		// The first source doesn't serve reflecttest.proto, so it neither
+		// answers nor conflicts.
+		file, err := reflector.descriptorResolver.FindFileByPath(reflecttestPath)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if file.Messages().ByName("Extra") == nil {
+			t.Fatal("expected file from second source")
+		}
+		var numbers []protoreflect.FieldNumber
+		reflector.extensionResolver.RangeExtensionsByMessage("connect.reflecttest.v1.Extendable", func(ext protoreflect.ExtensionType) bool {
+			numbers = append(numbers, ext.TypeDescriptor().Number())
+			return true
+		})
+		if len(numbers) != 0 {
+			t.Fatalf("expected extensions from the restricted source to be hidden, got %v", numbers)
+		}
+	})
+	t.Run("source_transforms", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector([]*Reflector{
+			NewStaticReflector(actualServiceName, testServiceName),
+		}, nil, WithoutSourceCodeInfo())
+		scrubbed := NewAggregateReflector([]*Reflector{
+			NewReflector(&staticNames{names: []string{testServiceName}}, WithoutLanguageOptions()),
+		}, nil)
+		for _, testCase := range []struct {
+			reflector *Reflector
+			goPackage bool
+		}{
+			{reflector: reflector, goPackage: true},
+			{reflector: scrubbed, goPackage: false},
+		} {
+			file, err := testCase.reflector.descriptorResolver.FindFileByPath(reflecttestPath)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			fileProto := testCase.reflector.newFileProto(file, nil)
+			if goPackage := fileProto.GetOptions().GetGoPackage() != ""; goPackage != testCase.goPackage {
+				t.Fatalf("expected go_package to be present: %t ; got %t", testCase.goPackage, goPackage)
+			}
+		}
+	})
+	t.Run("extensions", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewAggregateReflector(newSources(), nil)
+		var numbers []protoreflect.FieldNumber
+		reflector.extensionResolver.RangeExtensionsByMessage("connect.reflecttest.v1.Extendable", func(ext protoreflect.ExtensionType) bool {
+			numbers = append(numbers, ext.TypeDescriptor().Number())
+			return true
+		})
+		if len(numbers) != 2 {
+			t.Fatalf("expected each extension once, got %v", numbers)
+		}
+	})
+}
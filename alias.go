@@ -0,0 +1,433 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// AliasResolver serves the descriptors from another resolver under different
+// file paths and package names. It's useful for schemas that are compiled
+// into the binary under a private name to avoid conflicting registrations,
+// like vendored copies of Google's APIs: clients see the schema under its
+// authoritative name, so tools and the Protobuf runtime can recognize it.
+//
+// Aliases are configured with WithPathAlias and WithPackageAlias. Files whose
+// path or package is aliased are served only under their new path and names,
+// and references to aliased files and types are rewritten in every file the
+// resolver serves, including files that aren't aliased themselves. Files that
+// import aliased files, directly or indirectly, are rebuilt so that their
+// imports are the aliased files, and private names never leak through the
+// import chain. If an aliased name collides with a name in the underlying resolver, the aliased
+// descriptor wins.
+//
+// AliasResolver implements protodesc.Resolver and DescriptorResolverContext,
+// and it passes the context on to context-aware resolvers. It also implements
+// FileRanger, so Reflectors can find the aliased extensions, but it only
+// ranges over files if the underlying resolver implements FileRanger too.
+// Aliased files are built on first use and cached, so they keep their
+// identity across calls.
+type AliasResolver struct {
+	resolver protodesc.Resolver
+	paths    []alias
+	packages []alias
+
+	mu    sync.Mutex
+	files map[string]*aliasedFile // by original path
+}
+
+// AliasOption configures an AliasResolver.
+type AliasOption interface {
+	apply(*AliasResolver)
+}
+
+// NewAliasResolver constructs an AliasResolver that serves the descriptors
+// from the given resolver.
+func NewAliasResolver(resolver protodesc.Resolver, options ...AliasOption) *AliasResolver {
+	aliasResolver := &AliasResolver{
+		resolver: resolver,
+		files:    make(map[string]*aliasedFile),
+	}
+	for _, option := range options {
+		option.apply(aliasResolver)
+	}
+	return aliasResolver
+}
+
+// WithPathAlias serves files whose paths are in the directory from as if they
+// were in the directory to: with a path alias from "vendor/google" to
+// "google", the file "vendor/google/type/date.proto" is served as
+// "google/type/date.proto". Either directory may be empty, to remove or add a
+// directory prefix.
+//
+// If several aliases match a path, the first one applies.
+func WithPathAlias(from, to string) AliasOption {
+	return &aliasOption{
+		alias:    alias{from: strings.Trim(from, "/"), to: strings.Trim(to, "/"), separator: '/'},
+		forPaths: true,
+	}
+}
+
+// WithPackageAlias serves the package from and its sub-packages as if they
+// were the package to: with a package alias from "vendor.google" to "google",
+// the message "vendor.google.type.Date" is served as "google.type.Date".
+// Either package may be empty, to remove or add a package prefix.
+//
+// A package alias doesn't change file paths, so it's usually combined with a
+// path alias. If several aliases match a package, the first one applies.
+func WithPackageAlias(from, to string) AliasOption {
+	return &aliasOption{
+		alias: alias{from: strings.Trim(from, "."), to: strings.Trim(to, "."), separator: '.'},
+	}
+}
+
+// FindFileByPath implements protodesc.Resolver.
+func (r *AliasResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return r.FindFileByPathContext(context.Background(), path)
+}
+
+// FindDescriptorByName implements protodesc.Resolver.
+func (r *AliasResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return r.FindDescriptorByNameContext(context.Background(), name)
+}
+
+// FindFileByPathContext implements DescriptorResolverContext.
+func (r *AliasResolver) FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error) {
+	original := unalias(r.paths, path)
+	file, err := findFileByPath(ctx, r.resolver, original)
+	if errors.Is(err, protoregistry.NotFound) && original != path {
+		file, err = findFileByPath(ctx, r.resolver, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	aliased, err := r.aliasFile(ctx, file)
+	if err != nil {
+		return nil, err
+	}
+	if aliased.Path() != path {
+		// The file is served under another path.
+		return nil, protoregistry.NotFound
+	}
+	return aliased, nil
+}
+
+// FindDescriptorByNameContext implements DescriptorResolverContext.
+func (r *AliasResolver) FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	original := protoreflect.FullName(unalias(r.packages, string(name)))
+	desc, err := findDescriptorByName(ctx, r.resolver, original)
+	if errors.Is(err, protoregistry.NotFound) && original != name {
+		desc, err = findDescriptorByName(ctx, r.resolver, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	file, err := r.aliasFile(ctx, desc.ParentFile())
+	if err != nil {
+		return nil, err
+	}
+	aliased := correspondingDescriptor(file, desc)
+	if aliased == nil || aliased.FullName() != name {
+		// The descriptor is served under another name.
+		return nil, protoregistry.NotFound
+	}
+	return aliased, nil
+}
+
+// RangeFiles implements FileRanger. It calls f with the aliased form of each
+// file in the underlying resolver, if the resolver implements FileRanger.
+// Files that can't be aliased are skipped.
+func (r *AliasResolver) RangeFiles(f func(protoreflect.FileDescriptor) bool) {
+	files, ok := r.resolver.(FileRanger)
+	if !ok {
+		return
+	}
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		aliased, err := r.aliasFile(context.Background(), file)
+		if err != nil {
+			return true
+		}
+		return f(aliased)
+	})
+}
+
+// NumFiles returns the number of files in the underlying resolver, if it
+// reports it (as *protoregistry.Files does), and zero otherwise.
+func (r *AliasResolver) NumFiles() int {
+	if counter, ok := r.resolver.(interface{ NumFiles() int }); ok {
+		return counter.NumFiles()
+	}
+	return 0
+}
+
+type alias struct {
+	from, to  string
+	separator byte
+}
+
+// replace replaces the alias's prefix in the name, if it matches.
+func (a alias) replace(name string) (string, bool) {
+	return replacePrefix(name, a.from, a.to, a.separator)
+}
+
+// replacePrefix replaces the leading components of name that match from, if
+// any, with to. Components are separated by separator, and an empty prefix
+// matches every name.
+func replacePrefix(name, from, to string, separator byte) (string, bool) {
+	var rest string
+	switch {
+	case from == "":
+		rest = name
+	case name == from:
+		return to, true
+	case len(name) > len(from) && name[len(from)] == separator && strings.HasPrefix(name, from):
+		rest = name[len(from)+1:]
+	default:
+		return name, false
+	}
+	if to == "" || rest == "" {
+		return to + rest, true
+	}
+	return to + string(separator) + rest, true
+}
+
+// aliasName returns the name as it's served.
+func aliasName(aliases []alias, name string) string {
+	for _, alias := range aliases {
+		if aliased, ok := alias.replace(name); ok {
+			return aliased
+		}
+	}
+	return name
+}
+
+// unalias returns the name in the underlying resolver of a name that's served,
+// if it's an alias.
+func unalias(aliases []alias, name string) string {
+	for _, a := range aliases {
+		if original, ok := replacePrefix(name, a.to, a.from, a.separator); ok {
+			return original
+		}
+	}
+	return name
+}
+
+type aliasedFile struct {
+	original protoreflect.FileDescriptor
+	aliased  protoreflect.FileDescriptor
+}
+
+// aliasFile returns the file as it's served. Files that don't refer to any
+// aliased paths or names, and don't import any files that do, are returned as
+// they are.
+func (r *AliasResolver) aliasFile(ctx context.Context, file protoreflect.FileDescriptor) (protoreflect.FileDescriptor, error) {
+	if file.IsPlaceholder() {
+		return file, nil
+	}
+	cacheable := isCacheable(file)
+	if cacheable {
+		r.mu.Lock()
+		cached, ok := r.files[file.Path()]
+		r.mu.Unlock()
+		if ok && cached.original == file {
+			return cached.aliased, nil
+		}
+	}
+	fileProto := protodesc.ToFileDescriptorProto(file)
+	rebuild := r.rewriteFile(fileProto)
+	if !rebuild {
+		// Files that import aliased files, directly or indirectly, must be
+		// rebuilt too, so that their imports are the aliased files.
+		var err error
+		if rebuild, err = r.importsAliasedFile(ctx, file); err != nil {
+			return nil, err
+		}
+	}
+	aliased := file
+	if rebuild {
+		var err error
+		aliased, err = (protodesc.FileOptions{AllowUnresolvable: true}).New(fileProto, &aliasImports{ctx: ctx, resolver: r})
+		if err != nil {
+			return nil, fmt.Errorf("alias %q: %w", file.Path(), err)
+		}
+	}
+	if !cacheable {
+		return aliased, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if cached, ok := r.files[file.Path()]; ok && cached.original == file {
+		// Another goroutine aliased the file first.
+		return cached.aliased, nil
+	}
+	r.files[file.Path()] = &aliasedFile{original: file, aliased: aliased}
+	return aliased, nil
+}
+
+// importsAliasedFile reports whether any of the file's imports are served
+// as a different descriptor. Aliased files are cached, so each import is only
+// checked once.
+func (r *AliasResolver) importsAliasedFile(ctx context.Context, file protoreflect.FileDescriptor) (bool, error) {
+	imports := file.Imports()
+	for i := range imports.Len() {
+		imported := imports.Get(i).FileDescriptor
+		aliased, err := r.aliasFile(ctx, imported)
+		if err != nil {
+			return false, err
+		}
+		if aliased != imported {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// rewriteFile rewrites the paths and names in the file as they're served, and
+// reports whether anything changed.
+func (r *AliasResolver) rewriteFile(fileProto *descriptorpb.FileDescriptorProto) bool {
+	changed := false
+	rewrite := func(value *string, aliases []alias) {
+		if aliased := aliasName(aliases, *value); aliased != *value {
+			*value = aliased
+			changed = true
+		}
+	}
+	rewriteType := func(typeName *string) {
+		if typeName == nil || !strings.HasPrefix(*typeName, ".") {
+			return
+		}
+		name := (*typeName)[1:]
+		rewrite(&name, r.packages)
+		*typeName = "." + name
+	}
+	rewriteFields := func(fields []*descriptorpb.FieldDescriptorProto) {
+		for _, field := range fields {
+			rewriteType(field.TypeName)
+			rewriteType(field.Extendee)
+		}
+	}
+	var rewriteMessages func([]*descriptorpb.DescriptorProto)
+	rewriteMessages = func(messages []*descriptorpb.DescriptorProto) {
+		for _, message := range messages {
+			rewriteFields(message.GetField())
+			rewriteFields(message.GetExtension())
+			rewriteMessages(message.GetNestedType())
+		}
+	}
+
+	rewrite(fileProto.Name, r.paths)
+	if fileProto.Package != nil {
+		rewrite(fileProto.Package, r.packages)
+	}
+	for i := range fileProto.GetDependency() {
+		rewrite(&fileProto.Dependency[i], r.paths)
+	}
+	rewriteMessages(fileProto.GetMessageType())
+	rewriteFields(fileProto.GetExtension())
+	for _, service := range fileProto.GetService() {
+		for _, method := range service.GetMethod() {
+			rewriteType(method.InputType)
+			rewriteType(method.OutputType)
+		}
+	}
+	return changed
+}
+
+// correspondingDescriptor returns the descriptor in the aliased file that
+// corresponds to the given descriptor from the original file. Aliasing
+// doesn't change the structure of files, so the descriptor is at the same
+// position.
+func correspondingDescriptor(file protoreflect.FileDescriptor, desc protoreflect.Descriptor) protoreflect.Descriptor {
+	if _, ok := desc.(protoreflect.FileDescriptor); ok {
+		return file
+	}
+	parent := correspondingDescriptor(file, desc.Parent())
+	// Files and messages may contain messages, enums, and extensions.
+	type container interface {
+		Messages() protoreflect.MessageDescriptors
+		Enums() protoreflect.EnumDescriptors
+		Extensions() protoreflect.ExtensionDescriptors
+	}
+	switch desc := desc.(type) {
+	case protoreflect.MessageDescriptor:
+		if parent, ok := parent.(container); ok {
+			return parent.Messages().Get(desc.Index())
+		}
+	case protoreflect.EnumDescriptor:
+		if parent, ok := parent.(container); ok {
+			return parent.Enums().Get(desc.Index())
+		}
+	case protoreflect.EnumValueDescriptor:
+		if parent, ok := parent.(protoreflect.EnumDescriptor); ok {
+			return parent.Values().Get(desc.Index())
+		}
+	case protoreflect.FieldDescriptor:
+		if desc.IsExtension() {
+			if parent, ok := parent.(container); ok {
+				return parent.Extensions().Get(desc.Index())
+			}
+		} else if parent, ok := parent.(protoreflect.MessageDescriptor); ok {
+			return parent.Fields().Get(desc.Index())
+		}
+	case protoreflect.OneofDescriptor:
+		if parent, ok := parent.(protoreflect.MessageDescriptor); ok {
+			return parent.Oneofs().Get(desc.Index())
+		}
+	case protoreflect.ServiceDescriptor:
+		return file.Services().Get(desc.Index())
+	case protoreflect.MethodDescriptor:
+		if parent, ok := parent.(protoreflect.ServiceDescriptor); ok {
+			return parent.Methods().Get(desc.Index())
+		}
+	}
+	return nil
+}
+
+// aliasImports resolves the imports of aliased files, passing on the context
+// of the lookup that required them.
+type aliasImports struct {
+	ctx      context.Context //nolint:containedctx
+	resolver *AliasResolver
+}
+
+func (i *aliasImports) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return i.resolver.FindFileByPathContext(i.ctx, path)
+}
+
+func (i *aliasImports) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return i.resolver.FindDescriptorByNameContext(i.ctx, name)
+}
+
+type aliasOption struct {
+	alias    alias
+	forPaths bool
+}
+
+func (o *aliasOption) apply(resolver *AliasResolver) {
+	if o.forPaths {
+		resolver.paths = append(resolver.paths, o.alias)
+		return
+	}
+	resolver.packages = append(resolver.packages, o.alias)
+}
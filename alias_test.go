@@ -0,0 +1,247 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestAliasResolver(t *testing.T) {
+	t.Parallel()
+	newConnectextResolver := func() *AliasResolver {
+		return NewAliasResolver(
+			protoregistry.GlobalFiles,
+			WithPathAlias("connectext/grpc/", "grpc"),
+			WithPackageAlias("connectext.grpc", "grpc"),
+		)
+	}
+
+	t.Run("aliased_file", func(t *testing.T) {
+		t.Parallel()
+		resolver := newConnectextResolver()
+		file, err := resolver.FindFileByPath("grpc/reflection/v1/reflection.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if file.Package() != "grpc.reflection.v1" {
+			t.Fatalf("unexpected package: %s", file.Package())
+		}
+		method := file.Services().ByName("ServerReflection").Methods().ByName(methodName)
+		if name := method.Input().FullName(); name != "grpc.reflection.v1.ServerReflectionRequest" {
+			t.Fatalf("unexpected input type: %s", name)
+		}
+		desc, err := resolver.FindDescriptorByName("grpc.reflection.v1.ServerReflectionRequest.file_by_filename")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if desc.ParentFile() != file {
+			t.Fatal("expected aliased files to be cached")
+		}
+		if _, ok := desc.(protoreflect.FieldDescriptor); !ok {
+			t.Fatalf("expected a field, got %v", desc)
+		}
+	})
+	t.Run("original_names", func(t *testing.T) {
+		t.Parallel()
+		resolver := newConnectextResolver()
+		if _, err := resolver.FindFileByPath("connectext/grpc/reflection/v1/reflection.proto"); !errors.Is(err, protoregistry.NotFound) {
+			t.Fatalf("expected original path to be hidden, got %v", err)
+		}
+		if _, err := resolver.FindDescriptorByName(actualServiceName); !errors.Is(err, protoregistry.NotFound) {
+			t.Fatalf("expected original name to be hidden, got %v", err)
+		}
+	})
+	t.Run("unaliased_file", func(t *testing.T) {
+		t.Parallel()
+		resolver := newConnectextResolver()
+		const path = "connect/reflecttest/v1/reflecttest.proto"
+		expected, err := protoregistry.GlobalFiles.FindFileByPath(path)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		file, err := resolver.FindFileByPath(path)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if file != expected {
+			t.Fatal("expected files without aliased references to be served as they are")
+		}
+	})
+	t.Run("dependent_file", func(t *testing.T) {
+		t.Parallel()
+		resolver := NewAliasResolver(
+			newVendoredFiles(t),
+			WithPathAlias("vendor/google", "google"),
+			WithPackageAlias("vendor.google", "google"),
+		)
+		file, err := resolver.FindFileByPath("acme/v1/user.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if path := file.Imports().Get(0).Path(); path != "google/type/date.proto" {
+			t.Fatalf("unexpected import: %s", path)
+		}
+		field := file.Messages().ByName("User").Fields().ByName("birthday")
+		if name := field.Message().FullName(); name != "google.type.Date" {
+			t.Fatalf("unexpected field type: %s", name)
+		}
+		date, err := resolver.FindDescriptorByName("google.type.Date")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if field.Message() != date {
+			t.Fatal("expected dependent files to import the aliased files")
+		}
+		var paths []string
+		resolver.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+			paths = append(paths, file.Path())
+			return true
+		})
+		sort.Strings(paths)
+		if expected := []string{"acme/v1/account.proto", "acme/v1/user.proto", "google/type/date.proto"}; !reflect.DeepEqual(expected, paths) {
+			t.Fatalf("unexpected files: want %v ; got %v", expected, paths)
+		}
+	})
+	t.Run("indirect_dependency", func(t *testing.T) {
+		t.Parallel()
+		stream := newTestStream(t, NewReflector(
+			&staticNames{names: []string{"acme.v1.AccountService"}},
+			WithDescriptorResolver(NewAliasResolver(
+				newVendoredFiles(t),
+				WithPathAlias("vendor/google", "google"),
+				WithPackageAlias("vendor.google", "google"),
+			)),
+		))
+		descriptors, err := stream.FileByFilename("acme/v1/account.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		paths := make([]string, len(descriptors))
+		for i, fileProto := range descriptors {
+			paths[i] = fileProto.GetName()
+		}
+		if expected := []string{"acme/v1/account.proto", "acme/v1/user.proto", "google/type/date.proto"}; !reflect.DeepEqual(expected, paths) {
+			t.Fatalf("unexpected files: want %v ; got %v", expected, paths)
+		}
+		if dependencies := descriptors[1].GetDependency(); !reflect.DeepEqual([]string{"google/type/date.proto"}, dependencies) {
+			t.Fatalf("expected acme/v1/user.proto to import the aliased file, got %v", dependencies)
+		}
+		if pkg := descriptors[2].GetPackage(); pkg != "google.type" {
+			t.Fatalf("unexpected package: %s", pkg)
+		}
+	})
+	t.Run("reflector", func(t *testing.T) {
+		t.Parallel()
+		const serviceName = "grpc.reflection.v1.ServerReflection"
+		stream := newTestStream(t, NewReflector(
+			&staticNames{names: []string{serviceName}},
+			WithDescriptorResolver(newConnectextResolver()),
+		))
+		descriptors, err := stream.FileContainingSymbol(serviceName)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(descriptors) != 1 || descriptors[0].GetPackage() != "grpc.reflection.v1" {
+			t.Fatalf("unexpected files: %v", descriptors)
+		}
+		method := descriptors[0].GetService()[0].GetMethod()[0]
+		if inputType := method.GetInputType(); inputType != ".grpc.reflection.v1.ServerReflectionRequest" {
+			t.Fatalf("unexpected input type: %s", inputType)
+		}
+	})
+}
+
+func TestReplacePrefix(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name, from, to string
+		expected       string
+		ok             bool
+	}{
+		{name: "vendor.google.type", from: "vendor.google", to: "google", expected: "google.type", ok: true},
+		{name: "vendor.google", from: "vendor.google", to: "google", expected: "google", ok: true},
+		{name: "vendor.googleapis", from: "vendor.google", to: "google", expected: "vendor.googleapis"},
+		{name: "vendor.google.type", from: "vendor", to: "", expected: "google.type", ok: true},
+		{name: "vendor", from: "vendor", to: "", expected: "", ok: true},
+		{name: "google.type", from: "", to: "vendor", expected: "vendor.google.type", ok: true},
+	}
+	for _, testCase := range testCases {
+		actual, ok := replacePrefix(testCase.name, testCase.from, testCase.to, '.')
+		if actual != testCase.expected || ok != testCase.ok {
+			t.Errorf(
+				"replacePrefix(%q, %q, %q): want %q, %t ; got %q, %t",
+				testCase.name, testCase.from, testCase.to, testCase.expected, testCase.ok, actual, ok,
+			)
+		}
+	}
+}
+
+// newVendoredFiles returns a registry with a vendored copy of a Google API,
+// vendor/google/type/date.proto, a file that imports it, acme/v1/user.proto,
+// and a file that imports that one, acme/v1/account.proto.
+func newVendoredFiles(t *testing.T) *protoregistry.Files {
+	t.Helper()
+	files := &protoregistry.Files{}
+	registerFile(t, files, &descriptorpb.FileDescriptorProto{
+		Name:        proto.String("vendor/google/type/date.proto"),
+		Package:     proto.String("vendor.google.type"),
+		Syntax:      proto.String("proto3"),
+		MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Date")}},
+	})
+	registerFile(t, files, &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/v1/user.proto"),
+		Package:    proto.String("acme.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"vendor/google/type/date.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("User"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("birthday"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".vendor.google.type.Date"),
+				JsonName: proto.String("birthday"),
+			}},
+		}},
+	})
+	registerFile(t, files, &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/v1/account.proto"),
+		Package:    proto.String("acme.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"acme/v1/user.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Account"),
+			Field: []*descriptorpb.FieldDescriptorProto{{
+				Name:     proto.String("owner"),
+				Number:   proto.Int32(1),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+				TypeName: proto.String(".acme.v1.User"),
+				JsonName: proto.String("owner"),
+			}},
+		}},
+		Service: []*descriptorpb.ServiceDescriptorProto{{Name: proto.String("AccountService")}},
+	})
+	return files
+}
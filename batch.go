@@ -0,0 +1,124 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+	grpcreflectv1 "connectrpc.com/grpcreflect/internal/gen/go/connect/grpcreflect/v1"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+)
+
+const (
+	// BatchServiceName is the fully-qualified name of the batch reflection
+	// service, a unary companion to the gRPC server reflection API.
+	BatchServiceName = "connect.grpcreflect.v1.BatchReflectionService"
+
+	serviceURLPathBatch = "/" + BatchServiceName + "/"
+	batchMethodName     = "BatchServerReflectionInfo"
+
+	defaultBatchCacheControl = "private, max-age=300"
+)
+
+// NewBatchHandler constructs an implementation of the batch reflection
+// service, which answers a list of server reflection requests with a single
+// unary RPC. Unlike the handlers from NewHandlerV1 and NewHandlerV1Alpha, it
+// supports HTTP/1.1, so it works with browsers and proxies that don't support
+// HTTP/2. Each batch is answered exactly as if its requests had been sent on
+// a single reflection stream. It returns an HTTP handler and the path on which
+// to mount it.
+//
+// The RPC has no side effects, so clients using the Connect protocol may call
+// it with HTTP GET requests. Responses to GET requests include a Cache-Control
+// header (see WithBatchCacheControl), which lets browsers cache schema
+// lookups.
+//
+// Clients created with NewClient use the batch service automatically when
+// bidirectional streaming isn't possible. They send one request per RPC, so
+// unlike on a stream, files aren't deduplicated across requests: each
+// response includes the requested file's imports again, as configured by
+// WithFileDependencies. Clients that know all of their requests up front
+// should send them in a single batch instead.
+func NewBatchHandler(reflector *Reflector, options ...connect.HandlerOption) (string, http.Handler) {
+	options = append([]connect.HandlerOption{
+		connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+	}, options...)
+	return serviceURLPathBatch, connect.NewUnaryHandler(
+		serviceURLPathBatch+batchMethodName,
+		reflector.batchServerReflectionInfo,
+		options...,
+	)
+}
+
+// WithBatchCacheControl sets the Cache-Control header on successful responses
+// to batch reflection requests made with HTTP GET. If the value is empty, the
+// header isn't set. By default, the header is "private, max-age=300", which
+// lets each client reuse responses for five minutes but keeps shared caches,
+// like CDNs, from storing them.
+//
+// If every caller sees the same schema, "public, max-age=300" lets shared
+// caches serve responses too. Don't allow that if the Reflector's Namer or
+// resolvers answer differently depending on who's asking (see NamerContext),
+// or shared caches may serve one caller's schema to another.
+func WithBatchCacheControl(value string) Option {
+	return &batchCacheControlOption{value: value}
+}
+
+// batchServerReflectionInfo implements the batch reflection service.
+func (r *Reflector) batchServerReflectionInfo(
+	ctx context.Context,
+	req *connect.Request[grpcreflectv1.BatchServerReflectionInfoRequest],
+) (*connect.Response[grpcreflectv1.BatchServerReflectionInfoResponse], error) {
+	if err := r.acquireStream(); err != nil {
+		return nil, err
+	}
+	defer r.releaseStream()
+	ctx = newStreamInfoContext(ctx, StreamInfo{
+		Spec:          req.Spec(),
+		Peer:          req.Peer(),
+		RequestHeader: req.Header(),
+	})
+	session := newReflectionSession()
+	ctx = newSessionContext(ctx, session)
+	requests := req.Msg.GetRequests()
+	responses := make([]*reflectionv1.ServerReflectionResponse, 0, len(requests))
+	for _, request := range requests {
+		response, err := r.answer(ctx, request, session)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, response)
+	}
+	res := connect.NewResponse(&grpcreflectv1.BatchServerReflectionInfoResponse{
+		Responses: responses,
+	})
+	if req.HTTPMethod() == http.MethodGet && r.batchCacheControl != "" {
+		res.Header().Set("Cache-Control", r.batchCacheControl)
+	}
+	if r.fingerprintSchema {
+		r.setSchemaFingerprint(ctx, res.Header())
+	}
+	return res, nil
+}
+
+type batchCacheControlOption struct {
+	value string
+}
+
+func (o *batchCacheControlOption) apply(reflector *Reflector) {
+	reflector.batchCacheControl = o.value
+}
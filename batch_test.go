@@ -0,0 +1,227 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	grpcreflectv1 "connectrpc.com/grpcreflect/internal/gen/go/connect/grpcreflect/v1"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestBatchHandler(t *testing.T) {
+	t.Parallel()
+	newBatchClient := func(t *testing.T, options ...Option) *batchClient {
+		t.Helper()
+		reflector := NewReflector(&staticNames{names: []string{actualServiceName}}, options...)
+		mux := http.NewServeMux()
+		mux.Handle(NewBatchHandler(reflector))
+		// Only HTTP/1.1 is supported.
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return connect.NewClient[
+			grpcreflectv1.BatchServerReflectionInfoRequest,
+			grpcreflectv1.BatchServerReflectionInfoResponse,
+		](
+			server.Client(),
+			server.URL+serviceURLPathBatch+batchMethodName,
+			connect.WithHTTPGet(),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		)
+	}
+	fileRequest := &reflectionv1.ServerReflectionRequest{
+		MessageRequest: &reflectionv1.ServerReflectionRequest_FileByFilename{
+			FileByFilename: "connect/reflecttest/v1/reflecttest_ext.proto",
+		},
+	}
+	batch := &grpcreflectv1.BatchServerReflectionInfoRequest{
+		Requests: []*reflectionv1.ServerReflectionRequest{
+			{MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{}},
+			fileRequest,
+			fileRequest,
+		},
+	}
+
+	t.Run("get", func(t *testing.T) {
+		t.Parallel()
+		req := connect.NewRequest(batch)
+		res, err := newBatchClient(t).CallUnary(t.Context(), req)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if req.HTTPMethod() != http.MethodGet {
+			t.Fatalf("expected GET request, got %s", req.HTTPMethod())
+		}
+		if cacheControl := res.Header().Get("Cache-Control"); cacheControl != "private, max-age=300" {
+			t.Fatalf("unexpected Cache-Control header: %q", cacheControl)
+		}
+		responses := res.Msg.GetResponses()
+		if len(responses) != 3 {
+			t.Fatalf("expected 3 responses, got %d", len(responses))
+		}
+		if services := responses[0].GetListServicesResponse().GetService(); len(services) != 1 {
+			t.Fatalf("unexpected services: %v", services)
+		}
+		// The batch is treated like a stream, so dependencies are only sent once.
+		first := responses[1].GetFileDescriptorResponse().GetFileDescriptorProto()
+		second := responses[2].GetFileDescriptorResponse().GetFileDescriptorProto()
+		if len(first) != 2 || len(second) != 1 {
+			t.Fatalf("expected 2 files and then 1 file, got %d and %d", len(first), len(second))
+		}
+	})
+	t.Run("post", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewStaticReflector(actualServiceName)
+		mux := http.NewServeMux()
+		mux.Handle(NewBatchHandler(reflector))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		client := connect.NewClient[
+			grpcreflectv1.BatchServerReflectionInfoRequest,
+			grpcreflectv1.BatchServerReflectionInfoResponse,
+		](server.Client(), server.URL+serviceURLPathBatch+batchMethodName)
+		res, err := client.CallUnary(t.Context(), connect.NewRequest(batch))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cacheControl := res.Header().Get("Cache-Control"); cacheControl != "" {
+			t.Fatalf("unexpected Cache-Control header: %q", cacheControl)
+		}
+	})
+	t.Run("cache_control", func(t *testing.T) {
+		t.Parallel()
+		res, err := newBatchClient(t, WithBatchCacheControl("no-store")).CallUnary(t.Context(), connect.NewRequest(batch))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if cacheControl := res.Header().Get("Cache-Control"); cacheControl != "no-store" {
+			t.Fatalf("unexpected Cache-Control header: %q", cacheControl)
+		}
+	})
+	t.Run("limits", func(t *testing.T) {
+		t.Parallel()
+		_, err := newBatchClient(t, WithMaxRequestsPerStream(2)).CallUnary(t.Context(), connect.NewRequest(batch))
+		if connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Fatalf("unexpected code: want %v, got %v (%v)", connect.CodeResourceExhausted, connect.CodeOf(err), err)
+		}
+	})
+}
+
+func TestClientBatchFallback(t *testing.T) {
+	t.Parallel()
+	newClient := func(t *testing.T, withBatch bool) *Client {
+		t.Helper()
+		reflector := NewStaticReflector(actualServiceName)
+		mux := http.NewServeMux()
+		mux.Handle(NewHandlerV1(reflector))
+		mux.Handle(NewHandlerV1Alpha(reflector))
+		if withBatch {
+			mux.Handle(NewBatchHandler(reflector))
+		}
+		// Only HTTP/1.1 is supported, so bidi streams fail.
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		return NewClient(server.Client(), server.URL)
+	}
+
+	t.Run("fallback", func(t *testing.T) {
+		t.Parallel()
+		client := newClient(t, true)
+		for range 2 {
+			// The second stream should use the batch service from the start.
+			stream := client.NewStream(t.Context())
+			names, err := stream.ListServices()
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if expected := []protoreflect.FullName{actualServiceName}; !reflect.DeepEqual(expected, names) {
+				t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+			}
+			if _, err := stream.FileContainingSymbol(actualServiceName); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			_, err = stream.FileContainingSymbol("something.Thing")
+			if IsReflectionStreamBroken(err) || connect.CodeOf(err) != connect.CodeNotFound {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if procedure := stream.Spec().Procedure; procedure != serviceURLPathBatch+batchMethodName {
+				t.Fatalf("unexpected procedure: %s", procedure)
+			}
+			if _, err := stream.Close(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		}
+	})
+	t.Run("retry_bidi", func(t *testing.T) {
+		t.Parallel()
+		client := newClient(t, true)
+		stream := client.NewStream(t.Context())
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, _ = stream.Close()
+		if !client.NewStream(t.Context()).batch {
+			t.Fatal("expected new streams to use the batch service")
+		}
+		client.bidiFailedAt.Store(time.Now().Add(-bidiRetryInterval).UnixNano())
+		if client.NewStream(t.Context()).batch {
+			t.Fatal("expected new streams to try bidi streaming again")
+		}
+	})
+	t.Run("server_error", func(t *testing.T) {
+		t.Parallel()
+		// The server supports bidi streams, but fails with an "Unknown" error.
+		client := newTestClient(t, func(mux *http.ServeMux) {
+			mux.Handle(serviceURLPathV1+methodName, connect.NewBidiStreamHandler(
+				serviceURLPathV1+methodName,
+				func(context.Context, *connect.BidiStream[reflectionv1.ServerReflectionRequest, reflectionv1.ServerReflectionResponse]) error {
+					return connect.NewError(connect.CodeUnknown, errors.New("oops"))
+				},
+			))
+			mux.Handle(NewBatchHandler(NewStaticReflector(actualServiceName)))
+		})
+		stream := client.NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		_, err := stream.ListServices()
+		if !IsReflectionStreamBroken(err) || connect.CodeOf(err) != connect.CodeUnknown {
+			t.Fatalf("expected broken stream, got %v", err)
+		}
+		if client.bidiRecentlyFailed() {
+			t.Fatal("expected server errors not to disable bidi streaming")
+		}
+	})
+	t.Run("no_batch_service", func(t *testing.T) {
+		t.Parallel()
+		stream := newClient(t, false).NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		_, err := stream.ListServices()
+		if !IsReflectionStreamBroken(err) {
+			t.Fatalf("expected broken stream, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,121 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithDescriptorCacheLimit bounds the number of serialized file descriptors
+// that the Reflector caches. When the cache is full, the least recently used
+// file is evicted. If maxFiles is zero or negative, caching is disabled.
+//
+// By default, Reflectors cache the serialized form of every file they send,
+// so that it can be reused by later requests and other streams. Entries are
+// keyed by path, and they're discarded when the descriptor resolver returns a
+// different descriptor for the same path, so dynamic resolvers are safe to
+// use with the cache. Since a Reflector never caches more than one entry per
+// path, the default cache is only unbounded if the set of paths is.
+func WithDescriptorCacheLimit(maxFiles int) Option {
+	return &descriptorCacheLimitOption{maxFiles: maxFiles}
+}
+
+// descriptorCache is a cache of serialized file descriptors, keyed by path. A
+// zero limit means that the cache is unbounded.
+type descriptorCache struct {
+	limit int
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     list.List // of *descriptorCacheEntry, most recently used first
+}
+
+type descriptorCacheEntry struct {
+	file protoreflect.FileDescriptor
+	data []byte
+}
+
+func newDescriptorCache(limit int) *descriptorCache {
+	return &descriptorCache{
+		limit:   limit,
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// get returns the serialized form of the given file, if it's cached.
+func (c *descriptorCache) get(file protoreflect.FileDescriptor) ([]byte, bool) {
+	if !isCacheable(file) {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[file.Path()]
+	if !ok {
+		return nil, false
+	}
+	entry, _ := elem.Value.(*descriptorCacheEntry)
+	if entry.file != file {
+		// The resolver has a new version of the file.
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.data, true
+}
+
+// put caches the serialized form of the given file, replacing any previously
+// cached version and evicting the least recently used file if necessary.
+func (c *descriptorCache) put(file protoreflect.FileDescriptor, data []byte) {
+	if !isCacheable(file) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &descriptorCacheEntry{file: file, data: data}
+	if elem, ok := c.entries[file.Path()]; ok {
+		elem.Value = entry
+		c.lru.MoveToFront(elem)
+		return
+	}
+	c.entries[file.Path()] = c.lru.PushFront(entry)
+	if c.limit > 0 && c.lru.Len() > c.limit {
+		oldest := c.lru.Back()
+		oldestEntry, _ := c.lru.Remove(oldest).(*descriptorCacheEntry)
+		delete(c.entries, oldestEntry.file.Path())
+	}
+}
+
+// isCacheable reports whether we can safely compare the given descriptor to
+// cached descriptors. Comparing interface values panics if the dynamic types
+// aren't comparable, so we only cache descriptors that are pointers, which
+// includes all the descriptors created by the protobuf runtime.
+func isCacheable(file protoreflect.FileDescriptor) bool {
+	return reflect.TypeOf(file).Kind() == reflect.Pointer
+}
+
+type descriptorCacheLimitOption struct {
+	maxFiles int
+}
+
+func (o *descriptorCacheLimitOption) apply(reflector *Reflector) {
+	if o.maxFiles <= 0 {
+		reflector.cache = nil
+		return
+	}
+	reflector.cache = newDescriptorCache(o.maxFiles)
+}
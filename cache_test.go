@@ -0,0 +1,180 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"fmt"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDescriptorCache(t *testing.T) {
+	t.Parallel()
+	newFile := func(t *testing.T, path string) protoreflect.FileDescriptor {
+		t.Helper()
+		file, err := protodesc.NewFile(&descriptorpb.FileDescriptorProto{
+			Name:   proto.String(path),
+			Syntax: proto.String("proto3"),
+		}, &protoregistry.Files{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return file
+	}
+
+	t.Run("invalidation", func(t *testing.T) {
+		t.Parallel()
+		cache := newDescriptorCache(0)
+		original := newFile(t, "a.proto")
+		cache.put(original, []byte("original"))
+		if data, ok := cache.get(original); !ok || string(data) != "original" {
+			t.Fatalf("expected cache hit, got %q, %v", data, ok)
+		}
+		// A new descriptor for the same path must not be answered from the cache.
+		replacement := newFile(t, "a.proto")
+		if _, ok := cache.get(replacement); ok {
+			t.Fatal("expected cache miss for replaced descriptor")
+		}
+		cache.put(replacement, []byte("replacement"))
+		if data, ok := cache.get(replacement); !ok || string(data) != "replacement" {
+			t.Fatalf("expected cache hit, got %q, %v", data, ok)
+		}
+		if _, ok := cache.get(original); ok {
+			t.Fatal("expected cache miss for original descriptor")
+		}
+	})
+	t.Run("eviction", func(t *testing.T) {
+		t.Parallel()
+		cache := newDescriptorCache(2)
+		fileA, fileB, fileC := newFile(t, "a.proto"), newFile(t, "b.proto"), newFile(t, "c.proto")
+		cache.put(fileA, []byte("a"))
+		cache.put(fileB, []byte("b"))
+		// Use a.proto, so that b.proto is the least recently used.
+		if _, ok := cache.get(fileA); !ok {
+			t.Fatal("expected cache hit for a.proto")
+		}
+		cache.put(fileC, []byte("c"))
+		if _, ok := cache.get(fileB); ok {
+			t.Fatal("expected b.proto to be evicted")
+		}
+		for _, file := range []protoreflect.FileDescriptor{fileA, fileC} {
+			if _, ok := cache.get(file); !ok {
+				t.Fatalf("expected cache hit for %s", file.Path())
+			}
+		}
+	})
+	t.Run("uncomparable", func(t *testing.T) {
+		t.Parallel()
+		cache := newDescriptorCache(0)
+		file := dummyFile{path: "a.proto"}
+		cache.put(file, []byte("a"))
+		if _, ok := cache.get(file); ok {
+			t.Fatal("expected descriptors that aren't pointers to bypass the cache")
+		}
+	})
+	t.Run("options", func(t *testing.T) {
+		t.Parallel()
+		if NewReflector(&staticNames{}).cache == nil {
+			t.Fatal("expected cache to be enabled by default")
+		}
+		if NewReflector(&staticNames{}, WithDescriptorCacheLimit(0)).cache != nil {
+			t.Fatal("expected cache to be disabled")
+		}
+		if limit := NewReflector(&staticNames{}, WithDescriptorCacheLimit(10)).cache.limit; limit != 10 {
+			t.Fatalf("expected limit 10, got %d", limit)
+		}
+	})
+}
+
+func BenchmarkFileDescriptorWithDependencies(b *testing.B) {
+	root := newSyntheticSchema(b, 500, 20)
+	for _, bench := range []struct {
+		name    string
+		options []Option
+	}{
+		{name: "uncached", options: []Option{WithDescriptorCacheLimit(0)}},
+		{name: "cached"},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			reflector := NewReflector(&staticNames{}, bench.options...)
+			b.ReportAllocs()
+			for range b.N {
+				// Each iteration simulates a new stream, so nothing is deduplicated.
+				sent := &fileDescriptorNameSet{}
+				if _, err := fileDescriptorWithDependencies(root, FileDependenciesTransitive, sent, 0, reflector.encodeFile); err != nil {
+					b.Fatalf("unexpected error: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// newSyntheticSchema builds a chain of files, each of which imports the one
+// before it and defines the given number of messages. It returns the last
+// file, whose closure is the whole schema.
+func newSyntheticSchema(tb testing.TB, numFiles, numMessages int) protoreflect.FileDescriptor {
+	tb.Helper()
+	files := &protoregistry.Files{}
+	var last protoreflect.FileDescriptor
+	for i := range numFiles {
+		fileProto := &descriptorpb.FileDescriptorProto{
+			Name:    proto.String(fmt.Sprintf("synthetic/v1/file%d.proto", i)),
+			Package: proto.String("synthetic.v1"),
+			Syntax:  proto.String("proto3"),
+		}
+		if last != nil {
+			fileProto.Dependency = []string{last.Path()}
+		}
+		for j := range numMessages {
+			message := &descriptorpb.DescriptorProto{
+				Name: proto.String(fmt.Sprintf("Message%d_%d", i, j)),
+			}
+			for k := range 10 {
+				message.Field = append(message.Field, &descriptorpb.FieldDescriptorProto{
+					Name:     proto.String(fmt.Sprintf("field%d", k)),
+					JsonName: proto.String(fmt.Sprintf("field%d", k)),
+					Number:   proto.Int32(int32(k + 1)),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				})
+			}
+			if last != nil {
+				message.Field = append(message.Field, &descriptorpb.FieldDescriptorProto{
+					Name:     proto.String("previous"),
+					JsonName: proto.String("previous"),
+					Number:   proto.Int32(11),
+					Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+					Type:     descriptorpb.FieldDescriptorProto_TYPE_MESSAGE.Enum(),
+					TypeName: proto.String(fmt.Sprintf(".synthetic.v1.Message%d_%d", i-1, j)),
+				})
+			}
+			fileProto.MessageType = append(fileProto.MessageType, message)
+		}
+		file, err := protodesc.NewFile(fileProto, files)
+		if err != nil {
+			tb.Fatalf("unexpected error: %v", err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			tb.Fatalf("unexpected error: %v", err)
+		}
+		last = file
+	}
+	return last
+}
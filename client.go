@@ -20,10 +20,13 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
+	grpcreflectv1 "connectrpc.com/grpcreflect/internal/gen/go/connect/grpcreflect/v1"
 	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
 	"google.golang.org/protobuf/proto"
 	"google.golang.org/protobuf/reflect/protoreflect"
@@ -34,16 +37,40 @@ import (
 type Client struct {
 	clientV1        *reflectClient
 	clientV1Alpha   *reflectClient
+	clientBatch     *batchClient
 	v1unimplemented atomic.Bool
+	// bidiFailedAt holds the time, in Unix nanoseconds, when a stream last
+	// fell back to the batch service, or zero if none has.
+	bidiFailedAt atomic.Int64
 }
 
+// bidiRetryInterval is how long a Client sends batch RPCs after a stream falls
+// back to them, before trying a bidi stream again.
+const bidiRetryInterval = 5 * time.Minute
+
 // NewClient returns a client for interacting with the gRPC server reflection service.
 // The given HTTP client, base URL, and options are used to connect to the service.
 //
 // This client will try "v1" of the service first (grpc.reflection.v1.ServerReflection).
 // If this results in a "Not Implemented" error, the client will fall back to "v1alpha"
 // of the service (grpc.reflection.v1alpha.ServerReflection).
+//
+// If a stream fails before the server answers a single request because the server or a
+// proxy doesn't support HTTP/2 or bidirectional streaming, the client tries the batch
+// reflection service (see [NewBatchHandler]) instead. If that works, new streams send
+// each request as a separate unary RPC for the next five minutes, after which the client
+// tries a bidirectional stream again. Batch RPCs use HTTP GET when the client uses the
+// Connect protocol, so responses may be cached. Each RPC is answered as if it were a new
+// stream, though, so the server can't skip files it has already sent: every response
+// includes the requested file's imports, as configured on the server (see
+// WithFileDependencies), even if earlier responses included them too. A [ClientResolver]
+// that makes many lookups downloads considerably more data than it would on a stream.
+//
+// If the server mounts reflection under a path prefix (see [NewHandler]), include the
+// prefix in the base URL, as in "https://example.com/internal". A trailing slash is
+// ignored.
 func NewClient(httpClient connect.HTTPClient, baseURL string, options ...connect.ClientOption) *Client {
+	baseURL = strings.TrimRight(baseURL, "/")
 	clientV1 := connect.NewClient[reflectionv1.ServerReflectionRequest, reflectionv1.ServerReflectionResponse](
 		httpClient,
 		baseURL+serviceURLPathV1+methodName,
@@ -54,7 +81,15 @@ func NewClient(httpClient connect.HTTPClient, baseURL string, options ...connect
 		baseURL+serviceURLPathV1Alpha+methodName,
 		options...,
 	)
-	return &Client{clientV1: clientV1, clientV1Alpha: clientV1Alpha}
+	clientBatch := connect.NewClient[grpcreflectv1.BatchServerReflectionInfoRequest, grpcreflectv1.BatchServerReflectionInfoResponse](
+		httpClient,
+		baseURL+serviceURLPathBatch+batchMethodName,
+		append([]connect.ClientOption{
+			connect.WithHTTPGet(),
+			connect.WithIdempotency(connect.IdempotencyNoSideEffects),
+		}, options...)...,
+	)
+	return &Client{clientV1: clientV1, clientV1Alpha: clientV1Alpha, clientBatch: clientBatch}
 }
 
 // NewStream creates a new stream that is used to download reflection information from
@@ -69,15 +104,25 @@ func (c *Client) NewStream(ctx context.Context, options ...ClientStreamOption) *
 	clientStream := &ClientStream{
 		ctx:    ctx,
 		client: c,
+		batch:  c.bidiRecentlyFailed(),
 	}
 	for _, option := range options {
 		option.apply(&clientStream.clientStreamOptions)
 	}
-	// warm-up the stream
-	clientStream.getStream()
+	if !clientStream.batch {
+		// warm-up the stream
+		clientStream.getStream()
+	}
 	return clientStream
 }
 
+// bidiRecentlyFailed reports whether a stream fell back to the batch service within the
+// last bidiRetryInterval.
+func (c *Client) bidiRecentlyFailed() bool {
+	failedAt := c.bidiFailedAt.Load()
+	return failedAt != 0 && time.Since(time.Unix(0, failedAt)) < bidiRetryInterval
+}
+
 // ClientStreamOption is an option that can be provided when calling [Client.NewStream].
 type ClientStreamOption interface {
 	apply(*clientStreamOptions)
@@ -108,18 +153,37 @@ type ClientStream struct {
 	client *Client
 	clientStreamOptions
 
-	mu     sync.Mutex
-	stream *reflectStream
-	isV1   bool
+	mu       sync.Mutex
+	stream   *reflectStream
+	isV1     bool
+	received bool // whether the stream has ever received a response
+	// When the client uses the batch reflection service instead of a stream,
+	// lastBatch describes the most recent RPC.
+	batch     bool
+	lastBatch *batchCall
+
+	// If roundTripTimeout is positive, a request that isn't answered in time
+	// breaks the stream by calling cancel, which cancels the stream's
+	// context. Proxies use it to recover from stuck upstream streams.
+	roundTripTimeout time.Duration
+	cancel           context.CancelFunc
 }
 
-// Spec returns the specification for the reflection RPC.
+// Spec returns the specification for the reflection RPC. If the client is using the
+// batch reflection service, it describes the most recent batch RPC.
 func (cs *ClientStream) Spec() connect.Spec {
+	if call, ok := cs.getBatchCall(); ok {
+		return call.spec
+	}
 	return cs.getStream().Spec()
 }
 
-// Peer describes the server for the RPC.
+// Peer describes the server for the RPC. If the client is using the batch reflection
+// service, it describes the server for the most recent batch RPC.
 func (cs *ClientStream) Peer() connect.Peer {
+	if call, ok := cs.getBatchCall(); ok {
+		return call.peer
+	}
 	return cs.getStream().Peer()
 }
 
@@ -134,7 +198,13 @@ func (cs *ClientStream) Peer() connect.Peer {
 //
 // The operations that send a message on the stream are [ListServices], [FileByFilename],
 // [FileContainingSymbol], [FileContainingExtension], and [AllExtensionNumbers].
+//
+// If the client is using the batch reflection service, ResponseHeader returns the
+// headers of the most recent batch RPC.
 func (cs *ClientStream) ResponseHeader() http.Header {
+	if call, ok := cs.getBatchCall(); ok {
+		return call.header
+	}
 	return cs.getStream().ResponseHeader()
 }
 
@@ -263,6 +333,9 @@ func (cs *ClientStream) AllExtensionNumbers(messageName protoreflect.FullName) (
 
 // Close closes the stream and returns any trailers sent by the server.
 func (cs *ClientStream) Close() (http.Header, error) {
+	if call, ok := cs.getBatchCall(); ok {
+		return call.trailer, nil
+	}
 	stream := cs.getStream()
 
 	// half-close
@@ -343,6 +416,33 @@ func (cs *ClientStream) send(req *reflectionv1.ServerReflectionRequest) (*reflec
 	// often depend on the data in prior responses.
 	cs.mu.Lock()
 	defer cs.mu.Unlock()
+	if cs.roundTripTimeout > 0 {
+		stuck := time.AfterFunc(cs.roundTripTimeout, cs.cancel)
+		defer stuck.Stop()
+	}
+	resp, err := cs.roundTripLocked(req)
+	if err != nil {
+		if cs.ctx.Err() != nil && !IsReflectionStreamBroken(err) {
+			// Batch RPCs fail one at a time, but once the context is
+			// done, so is every later RPC.
+			err = &streamError{err: err}
+		}
+		return nil, err
+	}
+	if errResp := resp.GetErrorResponse(); errResp != nil {
+		code := connect.CodeInternal
+		if errResp.ErrorCode > 0 {
+			code = connect.Code(errResp.ErrorCode)
+		}
+		return nil, connect.NewWireError(code, errors.New(errResp.ErrorMessage))
+	}
+	return resp, nil
+}
+
+func (cs *ClientStream) roundTripLocked(req *reflectionv1.ServerReflectionRequest) (*reflectionv1.ServerReflectionResponse, error) {
+	if cs.batch {
+		return cs.batchRoundTripLocked(req)
+	}
 	for {
 		stream := cs.getStreamLocked()
 		if err := stream.Send(req); err != nil {
@@ -356,26 +456,92 @@ func (cs *ClientStream) send(req *reflectionv1.ServerReflectionRequest) (*reflec
 			if cs.shouldRetryLocked(err) {
 				continue
 			}
-			return nil, &streamError{err: err}
+			return cs.fallBackLocked(req, err)
 		}
 		resp, err := stream.Receive()
 		if err != nil {
 			if cs.shouldRetryLocked(err) {
 				continue
 			}
-			return nil, &streamError{err: err}
-		}
-		if errResp := resp.GetErrorResponse(); errResp != nil {
-			code := connect.CodeInternal
-			if errResp.ErrorCode > 0 {
-				code = connect.Code(errResp.ErrorCode)
-			}
-			return nil, connect.NewWireError(code, errors.New(errResp.ErrorMessage))
+			return cs.fallBackLocked(req, err)
 		}
+		cs.received = true
 		return resp, nil
 	}
 }
 
+// fallBackLocked is called when the stream fails. If the stream never worked because
+// the server (or a proxy) doesn't support bidirectional streaming, we try the batch
+// reflection service. If that doesn't work either, we report the stream's error.
+func (cs *ClientStream) fallBackLocked(req *reflectionv1.ServerReflectionRequest, streamErr error) (*reflectionv1.ServerReflectionResponse, error) {
+	if cs.received || !isBidiUnavailable(streamErr) {
+		return nil, &streamError{err: streamErr}
+	}
+	resp, err := cs.batchRoundTripLocked(req)
+	if err != nil {
+		return nil, &streamError{err: streamErr}
+	}
+	_ = cs.stream.CloseResponse()
+	cs.stream = nil
+	cs.batch = true
+	cs.client.bidiFailedAt.Store(time.Now().UnixNano())
+	return resp, nil
+}
+
+func (cs *ClientStream) batchRoundTripLocked(req *reflectionv1.ServerReflectionRequest) (*reflectionv1.ServerReflectionResponse, error) {
+	batchReq := connect.NewRequest(&grpcreflectv1.BatchServerReflectionInfoRequest{
+		Requests: []*reflectionv1.ServerReflectionRequest{req},
+	})
+	for k, v := range cs.headers {
+		batchReq.Header()[k] = v
+	}
+	batchResp, err := cs.client.clientBatch.CallUnary(cs.ctx, batchReq)
+	if err != nil {
+		return nil, err
+	}
+	cs.lastBatch = &batchCall{
+		spec:    batchReq.Spec(),
+		peer:    batchReq.Peer(),
+		header:  batchResp.Header(),
+		trailer: batchResp.Trailer(),
+	}
+	responses := batchResp.Msg.GetResponses()
+	if len(responses) != 1 {
+		return nil, fmt.Errorf("protocol error: server sent %d responses to a batch of 1 request", len(responses))
+	}
+	return responses[0], nil
+}
+
+// getBatchCall returns the most recent batch RPC, if the stream is using the batch
+// reflection service.
+func (cs *ClientStream) getBatchCall() (*batchCall, bool) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	if !cs.batch {
+		return nil, false
+	}
+	if cs.lastBatch == nil {
+		return &batchCall{header: http.Header{}, trailer: http.Header{}}, true
+	}
+	return cs.lastBatch, true
+}
+
+// isBidiUnavailable reports whether a stream's error suggests that the server or a proxy
+// doesn't support bidirectional streaming: either the method isn't routed at all, or the
+// HTTP response had an error status (like 505 HTTP Version Not Supported) that Connect
+// reports as "Unknown". Errors sent by a reflection server, including "Unknown" ones,
+// don't count.
+func isBidiUnavailable(err error) bool {
+	switch connect.CodeOf(err) {
+	case connect.CodeUnimplemented:
+		return true
+	case connect.CodeUnknown:
+		return !connect.IsWireError(err)
+	default:
+		return false
+	}
+}
+
 func (cs *ClientStream) shouldRetryLocked(err error) bool {
 	if connect.CodeOf(err) == connect.CodeUnimplemented && cs.isV1 {
 		// retry w/ v1alpha
@@ -388,6 +554,15 @@ func (cs *ClientStream) shouldRetryLocked(err error) bool {
 
 type reflectClient = connect.Client[reflectionv1.ServerReflectionRequest, reflectionv1.ServerReflectionResponse]
 type reflectStream = connect.BidiStreamForClient[reflectionv1.ServerReflectionRequest, reflectionv1.ServerReflectionResponse]
+type batchClient = connect.Client[grpcreflectv1.BatchServerReflectionInfoRequest, grpcreflectv1.BatchServerReflectionInfoResponse]
+
+// batchCall describes a call to the batch reflection service.
+type batchCall struct {
+	spec    connect.Spec
+	peer    connect.Peer
+	header  http.Header
+	trailer http.Header
+}
 
 type clientStreamOptions struct {
 	host    string
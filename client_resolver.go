@@ -0,0 +1,241 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ClientResolver resolves Protobuf descriptors by downloading them from a
+// server over a [ClientStream]. Files are fetched lazily, the first time they're
+// needed, and are then linked and cached for the lifetime of the resolver.
+//
+// Servers usually omit files from a response if they were already sent earlier
+// on the same stream, so the raw responses from [ClientStream.FileByFilename]
+// and friends may not contain a file's full set of dependencies. A ClientResolver
+// keeps every file it receives and, if an import still hasn't been seen, requests
+// it explicitly. The descriptors it returns are always fully linked.
+//
+// ClientResolver implements [protodesc.Resolver] and [ExtensionResolver], so it
+// can be used with [protodesc.NewFile], with [WithDescriptorResolver], and with
+// [WithExtensionResolver]. It is safe to call concurrently.
+//
+// If an operation returns an error for which [IsReflectionStreamBroken] returns
+// true, the underlying stream is broken, and the caller should create a new
+// stream and a new ClientResolver.
+type ClientResolver struct {
+	stream *ClientStream
+
+	mu     sync.Mutex
+	protos map[string]*descriptorpb.FileDescriptorProto
+	files  *protoregistry.Files
+	types  *dynamicpb.Types
+}
+
+// NewClientResolver returns a resolver that downloads descriptors using the
+// given stream. The caller remains responsible for closing the stream.
+func NewClientResolver(stream *ClientStream) *ClientResolver {
+	files := &protoregistry.Files{}
+	return &ClientResolver{
+		stream: stream,
+		protos: map[string]*descriptorpb.FileDescriptorProto{},
+		files:  files,
+		types:  dynamicpb.NewTypes(files),
+	}
+}
+
+// FindFileByPath returns the file with the given path, downloading it and its
+// imports from the server if necessary.
+//
+// If the server doesn't know about the file, the returned error wraps
+// [protoregistry.NotFound].
+func (r *ClientResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.linkLocked(path, nil)
+}
+
+// FindDescriptorByName returns the element with the given fully-qualified name,
+// downloading the file that defines it (and that file's imports) from the server
+// if necessary.
+//
+// If the server doesn't know about the element, the returned error wraps
+// [protoregistry.NotFound].
+func (r *ClientResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if desc, err := r.files.FindDescriptorByName(name); err == nil {
+		return desc, nil
+	}
+	files, err := r.stream.FileContainingSymbol(name)
+	if err != nil {
+		return nil, clientResolverError(err)
+	}
+	if err := r.linkAllLocked(files); err != nil {
+		return nil, err
+	}
+	desc, err := r.files.FindDescriptorByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("protocol error: reply to file_containing_symbol did not define %s: %w", name, err)
+	}
+	return desc, nil
+}
+
+// FindExtensionByName returns the extension with the given fully-qualified
+// name, downloading the file that defines it from the server if necessary.
+func (r *ClientResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	if _, err := r.FindDescriptorByName(field); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.types.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber returns the extension of the given message with the
+// given field number, downloading the file that defines it from the server if
+// necessary.
+func (r *ClientResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ext, err := r.types.FindExtensionByNumber(message, field); err == nil {
+		return ext, nil
+	}
+	files, err := r.stream.FileContainingExtension(message, field)
+	if err != nil {
+		return nil, clientResolverError(err)
+	}
+	if err := r.linkAllLocked(files); err != nil {
+		return nil, err
+	}
+	ext, err := r.types.FindExtensionByNumber(message, field)
+	if err != nil {
+		return nil, fmt.Errorf("protocol error: reply to file_containing_extension did not define extension %d of %s: %w", field, message, err)
+	}
+	return ext, nil
+}
+
+// RangeExtensionsByMessage calls f for each extension of the given message
+// that the server knows about. Since the signature doesn't allow errors to be
+// returned, extensions that can't be downloaded are silently skipped.
+func (r *ClientResolver) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	numbers, err := r.stream.AllExtensionNumbers(message)
+	if err != nil {
+		return
+	}
+	for _, number := range numbers {
+		ext, err := r.FindExtensionByNumber(message, number)
+		if err != nil {
+			continue
+		}
+		if !f(ext) {
+			return
+		}
+	}
+}
+
+func (r *ClientResolver) linkAllLocked(files []*descriptorpb.FileDescriptorProto) error {
+	r.addLocked(files)
+	for _, file := range files {
+		if _, err := r.linkLocked(file.GetName(), nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addLocked records downloaded files so that they're available for linking.
+// If we already have a file with the same name, we keep the one we got first.
+func (r *ClientResolver) addLocked(files []*descriptorpb.FileDescriptorProto) {
+	for _, file := range files {
+		if _, ok := r.protos[file.GetName()]; !ok {
+			r.protos[file.GetName()] = file
+		}
+	}
+}
+
+// linkLocked returns the linked descriptor for the given path, downloading the
+// file and any of its missing imports if necessary. The importedBy slice lists
+// the files whose linking led here, so that we can detect import cycles.
+func (r *ClientResolver) linkLocked(path string, importedBy []string) (protoreflect.FileDescriptor, error) {
+	if fd, err := r.files.FindFileByPath(path); err == nil {
+		return fd, nil
+	}
+	for _, importer := range importedBy {
+		if importer == path {
+			return nil, fmt.Errorf("import cycle involving %q", path)
+		}
+	}
+	fileProto, ok := r.protos[path]
+	if !ok {
+		files, err := r.stream.FileByFilename(path)
+		if err != nil {
+			return nil, clientResolverError(err)
+		}
+		r.addLocked(files)
+		if fileProto, ok = r.protos[path]; !ok {
+			return nil, fmt.Errorf("protocol error: reply to file_by_filename did not include %q", path)
+		}
+	}
+	importedBy = append(importedBy, path)
+	for _, dep := range fileProto.GetDependency() {
+		if _, err := r.linkLocked(dep, importedBy); err != nil {
+			return nil, err
+		}
+	}
+	fd, err := protodesc.NewFile(fileProto, r.files)
+	if err != nil {
+		return nil, fmt.Errorf("could not link %q: %w", path, err)
+	}
+	if err := r.files.RegisterFile(fd); err != nil {
+		return nil, fmt.Errorf("could not register %q: %w", path, err)
+	}
+	return fd, nil
+}
+
+// clientResolverError makes "Not Found" errors from the server recognizable as
+// protoregistry.NotFound, which is what callers of a protodesc.Resolver expect,
+// without hiding the underlying *connect.Error.
+func clientResolverError(err error) error {
+	if connect.CodeOf(err) == connect.CodeNotFound && !IsReflectionStreamBroken(err) {
+		return &notFoundError{err: err}
+	}
+	return err
+}
+
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string {
+	return e.err.Error()
+}
+
+func (e *notFoundError) Unwrap() error {
+	return e.err
+}
+
+func (e *notFoundError) Is(target error) bool {
+	return errors.Is(target, protoregistry.NotFound)
+}
@@ -0,0 +1,135 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestClientResolver(t *testing.T) {
+	t.Parallel()
+	client := newTestClient(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(NewStaticReflector(actualServiceName)))
+	})
+
+	newResolver := func(t *testing.T) (*ClientStream, *ClientResolver) {
+		t.Helper()
+		stream := client.NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		return stream, NewClientResolver(stream)
+	}
+
+	t.Run("find_descriptor_by_name", func(t *testing.T) {
+		t.Parallel()
+		_, resolver := newResolver(t)
+		desc, err := resolver.FindDescriptorByName("connect.reflecttest.v1.TestService.Do")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		method, ok := desc.(protoreflect.MethodDescriptor)
+		if !ok {
+			t.Fatalf("expected method descriptor, got %T", desc)
+		}
+		if method.Input().FullName() != "connect.reflecttest.v1.DoRequest" {
+			t.Fatalf("unexpected input type: %s", method.Input().FullName())
+		}
+		if method.Input().IsPlaceholder() {
+			t.Fatal("input type should be fully linked")
+		}
+	})
+
+	t.Run("find_file_by_path_after_dedupe", func(t *testing.T) {
+		t.Parallel()
+		stream, resolver := newResolver(t)
+		// Download reflecttest.proto outside of the resolver, so that the
+		// server won't send it again when we ask for reflecttest_ext.proto.
+		if _, err := stream.FileContainingSymbol("connect.reflecttest.v1.Extendable"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		fd, err := resolver.FindFileByPath("connect/reflecttest/v1/reflecttest_ext.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if fd.Imports().Len() != 1 || fd.Imports().Get(0).IsPlaceholder() {
+			t.Fatal("import of reflecttest_ext.proto should be fully linked")
+		}
+		// Descriptors must be usable to build a registry.
+		if _, err := protodesc.NewFile(protodesc.ToFileDescriptorProto(fd), resolver); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+
+	t.Run("extensions", func(t *testing.T) {
+		t.Parallel()
+		_, resolver := newResolver(t)
+		if _, err := resolver.FindDescriptorByName("connect.reflecttest.v1.Extendable"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		ext, err := resolver.FindExtensionByNumber("connect.reflecttest.v1.Extendable", 10)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if ext.TypeDescriptor().FullName() != "connect.reflecttest.v1.message" {
+			t.Fatalf("unexpected extension: %s", ext.TypeDescriptor().FullName())
+		}
+		ext, err = resolver.FindExtensionByName("connect.reflecttest.v1.localized_message")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if ext.TypeDescriptor().Number() != 11 {
+			t.Fatalf("unexpected extension number: %d", ext.TypeDescriptor().Number())
+		}
+		var numbers []protoreflect.FieldNumber
+		resolver.RangeExtensionsByMessage("connect.reflecttest.v1.Extendable", func(ext protoreflect.ExtensionType) bool {
+			numbers = append(numbers, ext.TypeDescriptor().Number())
+			return true
+		})
+		if expected := []protoreflect.FieldNumber{10, 11}; !reflect.DeepEqual(expected, numbers) {
+			t.Fatalf("unexpected extension numbers: want %v ; got %v", expected, numbers)
+		}
+	})
+
+	t.Run("not_found", func(t *testing.T) {
+		t.Parallel()
+		_, resolver := newResolver(t)
+		_, err := resolver.FindFileByPath("foo/bar/baz.proto")
+		expectResolverNotFound(t, err)
+		_, err = resolver.FindDescriptorByName("foo.bar.baz.Bedazzle")
+		expectResolverNotFound(t, err)
+		_, err = resolver.FindExtensionByNumber("connect.reflecttest.v1.Extendable", 29)
+		expectResolverNotFound(t, err)
+	})
+}
+
+func expectResolverNotFound(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, protoregistry.NotFound) {
+		t.Fatalf("expected protoregistry.NotFound, got %v", err)
+	}
+	if connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("unexpected code: want %v, got %v", connect.CodeNotFound, connect.CodeOf(err))
+	}
+}
@@ -18,7 +18,6 @@ import (
 	"context"
 	"errors"
 	"net/http"
-	"net/http/httptest"
 	"reflect"
 	"testing"
 
@@ -46,19 +45,13 @@ func TestClient(t *testing.T) {
 
 func testClient(t *testing.T, register func(server *http.ServeMux)) {
 	t.Helper()
-	mux := http.NewServeMux()
-	register(mux)
-	server := httptest.NewUnstartedServer(mux)
-	server.EnableHTTP2 = true
-	server.StartTLS()
-	t.Cleanup(server.Close)
+	client := newTestClient(t, register)
 
 	// We want to clean up ourselves below, to test stream.Close().
 	// So we don't want the context and thus the stream) to have
 	// already been canceled. So we don't use t.Context().
 	//nolint:usetesting
 	ctx := context.Background()
-	client := NewClient(server.Client(), server.URL, connect.WithGRPC())
 	stream := client.NewStream(ctx)
 	t.Cleanup(func() {
 		trailers, err := stream.Close()
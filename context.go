@@ -0,0 +1,163 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"net/http"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// NamerContext is an optional interface for Namers that need to know who's
+// asking: for example, to show some services only to authenticated callers.
+// When a Namer also implements NamerContext, Reflectors call NamesContext
+// instead of Names.
+//
+// The context is derived from the context of the reflection stream, so it
+// carries any values added by middleware or interceptors. Use
+// StreamInfoFromContext to retrieve the stream's request headers and peer.
+// Errors are sent back to the client, so they should usually be
+// [*connect.Error]s with an appropriate code.
+type NamerContext interface {
+	NamesContext(ctx context.Context) ([]string, error)
+}
+
+// DescriptorResolverContext is an optional interface for descriptor resolvers
+// that need to know who's asking. When the resolver passed to
+// WithDescriptorResolver also implements DescriptorResolverContext, Reflectors
+// call these methods instead of the corresponding protodesc.Resolver methods.
+//
+// The context is the same as the one passed to NamerContext. To hide a file or
+// symbol from the caller, return an error that wraps protoregistry.NotFound or a
+// [*connect.Error] with an appropriate code.
+type DescriptorResolverContext interface {
+	FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error)
+	FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error)
+}
+
+// ExtensionResolverContext is an optional interface for ExtensionResolvers
+// that need to know who's asking. When an ExtensionResolver also implements
+// ExtensionResolverContext, Reflectors call these methods instead of the
+// corresponding ExtensionResolver methods.
+//
+// The context is the same as the one passed to NamerContext.
+type ExtensionResolverContext interface {
+	FindExtensionByNumberContext(ctx context.Context, message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error)
+	RangeExtensionsByMessageContext(ctx context.Context, message protoreflect.FullName, f func(protoreflect.ExtensionType) bool)
+}
+
+// StreamInfo describes the reflection stream on whose behalf a context-aware
+// Namer or resolver is being called. Batches of requests sent to the handler
+// from NewBatchHandler are treated like streams.
+type StreamInfo struct {
+	// Spec describes the reflection RPC, including whether it's v1, v1alpha,
+	// or a batch.
+	Spec connect.Spec
+	// Peer describes the client.
+	Peer connect.Peer
+	// RequestHeader holds the headers sent by the client. It must not be
+	// modified.
+	RequestHeader http.Header
+}
+
+// StreamInfoFromContext returns the StreamInfo for the reflection stream that
+// the context belongs to. It's intended for use by implementations of
+// NamerContext, DescriptorResolverContext, and ExtensionResolverContext.
+func StreamInfoFromContext(ctx context.Context) (StreamInfo, bool) {
+	info, ok := ctx.Value(streamInfoKey{}).(StreamInfo)
+	return info, ok
+}
+
+type streamInfoKey struct{}
+
+func newStreamInfoContext(ctx context.Context, info StreamInfo) context.Context {
+	return context.WithValue(ctx, streamInfoKey{}, info)
+}
+
+type sessionKey struct{}
+
+func newSessionContext(ctx context.Context, session *reflectionSession) context.Context {
+	return context.WithValue(ctx, sessionKey{}, session)
+}
+
+// pinToSession returns the value pinned under key for the lifetime of the
+// context's reflection stream, calling load to pin it on first use. Outside of
+// a reflection stream, it returns the result of load.
+func pinToSession(ctx context.Context, key any, load func() any) any {
+	session, _ := ctx.Value(sessionKey{}).(*reflectionSession)
+	if session == nil {
+		return load()
+	}
+	session.pinnedMu.Lock()
+	defer session.pinnedMu.Unlock()
+	if value, ok := session.pinned[key]; ok {
+		return value
+	}
+	value := load()
+	if session.pinned == nil {
+		session.pinned = make(map[any]any, 1)
+	}
+	session.pinned[key] = value
+	return value
+}
+
+func namesContext(ctx context.Context, namer Namer) ([]string, error) {
+	if namer, ok := namer.(NamerContext); ok {
+		return namer.NamesContext(ctx)
+	}
+	return namer.Names(), nil
+}
+
+func findFileByPath(ctx context.Context, resolver protodesc.Resolver, path string) (protoreflect.FileDescriptor, error) {
+	if resolver, ok := resolver.(DescriptorResolverContext); ok {
+		return resolver.FindFileByPathContext(ctx, path)
+	}
+	return resolver.FindFileByPath(path)
+}
+
+func findDescriptorByName(ctx context.Context, resolver protodesc.Resolver, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if resolver, ok := resolver.(DescriptorResolverContext); ok {
+		return resolver.FindDescriptorByNameContext(ctx, name)
+	}
+	return resolver.FindDescriptorByName(name)
+}
+
+func findExtensionByNumber(
+	ctx context.Context,
+	resolver ExtensionResolver,
+	message protoreflect.FullName,
+	field protoreflect.FieldNumber,
+) (protoreflect.ExtensionType, error) {
+	if resolver, ok := resolver.(ExtensionResolverContext); ok {
+		return resolver.FindExtensionByNumberContext(ctx, message, field)
+	}
+	return resolver.FindExtensionByNumber(message, field)
+}
+
+func rangeExtensionsByMessage(
+	ctx context.Context,
+	resolver ExtensionResolver,
+	message protoreflect.FullName,
+	f func(protoreflect.ExtensionType) bool,
+) {
+	if resolver, ok := resolver.(ExtensionResolverContext); ok {
+		resolver.RangeExtensionsByMessageContext(ctx, message, f)
+		return
+	}
+	resolver.RangeExtensionsByMessage(message, f)
+}
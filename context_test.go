@@ -0,0 +1,161 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestContextAwareReflector(t *testing.T) {
+	t.Parallel()
+	const internalServiceName = "connect.reflecttest.v1.TestService"
+	visibility := &employeeOnlyVisibility{
+		public:   actualServiceName,
+		internal: internalServiceName,
+	}
+	reflector := NewReflector(visibility, WithDescriptorResolver(visibility))
+	client := newTestClient(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(reflector))
+	})
+
+	newStream := func(t *testing.T, employee bool) *ClientStream {
+		t.Helper()
+		var options []ClientStreamOption
+		if employee {
+			options = append(options, WithRequestHeaders(http.Header{"X-Employee": []string{"true"}}))
+		}
+		stream := client.NewStream(t.Context(), options...)
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		return stream
+	}
+
+	t.Run("employee", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, true)
+		names, err := stream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		expected := []protoreflect.FullName{actualServiceName, internalServiceName}
+		if !reflect.DeepEqual(expected, names) {
+			t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+		}
+		if _, err := stream.FileContainingSymbol(internalServiceName); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+	t.Run("public", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, false)
+		names, err := stream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		expected := []protoreflect.FullName{actualServiceName}
+		if !reflect.DeepEqual(expected, names) {
+			t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+		}
+		_, err = stream.FileContainingSymbol(internalServiceName)
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("unexpected code: want %v, got %v", connect.CodeNotFound, connect.CodeOf(err))
+		}
+		if _, err := stream.FileContainingSymbol(actualServiceName); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+	t.Run("names_error", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewReflector(&failingNamer{Namer: &staticNames{}})
+		stream := newTestStream(t, reflector)
+		_, err := stream.ListServices()
+		if IsReflectionStreamBroken(err) {
+			t.Fatalf("error should not break the stream: %v", err)
+		}
+		if connect.CodeOf(err) != connect.CodeUnavailable {
+			t.Fatalf("unexpected code: want %v, got %v", connect.CodeUnavailable, connect.CodeOf(err))
+		}
+	})
+}
+
+// employeeOnlyVisibility hides the internal service, and the file that defines
+// it, from callers that don't send an "X-Employee: true" header.
+type employeeOnlyVisibility struct {
+	public, internal protoreflect.FullName
+}
+
+func (v *employeeOnlyVisibility) Names() []string {
+	return []string{string(v.public)}
+}
+
+func (v *employeeOnlyVisibility) NamesContext(ctx context.Context) ([]string, error) {
+	if !isEmployee(ctx) {
+		return v.Names(), nil
+	}
+	return []string{string(v.public), string(v.internal)}, nil
+}
+
+func (v *employeeOnlyVisibility) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return globalFiles.FindFileByPath(path)
+}
+
+func (v *employeeOnlyVisibility) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return globalFiles.FindDescriptorByName(name)
+}
+
+func (v *employeeOnlyVisibility) FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error) {
+	file, err := v.FindFileByPath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !isEmployee(ctx) && file.Services().ByName(v.internal.Name()) != nil {
+		return nil, protoregistry.NotFound
+	}
+	return file, nil
+}
+
+func (v *employeeOnlyVisibility) FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	desc, err := v.FindDescriptorByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := v.FindFileByPathContext(ctx, desc.ParentFile().Path()); err != nil {
+		return nil, err
+	}
+	return desc, nil
+}
+
+func isEmployee(ctx context.Context) bool {
+	info, ok := StreamInfoFromContext(ctx)
+	return ok && info.RequestHeader.Get("X-Employee") == "true"
+}
+
+type failingNamer struct {
+	Namer
+}
+
+func (n *failingNamer) NamesContext(context.Context) ([]string, error) {
+	return nil, connect.NewError(connect.CodeUnavailable, errors.New("service registry is down"))
+}
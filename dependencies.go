@@ -0,0 +1,93 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+// FileDependencies controls which imports are sent along with a requested
+// file.
+type FileDependencies int
+
+const (
+	// FileDependenciesTransitive sends the requested file and every file it
+	// imports, directly or indirectly. Clients get everything they need to
+	// build the file's descriptor in a single round trip. This is the default.
+	FileDependenciesTransitive FileDependencies = iota + 1
+	// FileDependenciesDirect sends the requested file and the files it imports
+	// directly. Clients must ask for the remaining files themselves.
+	FileDependenciesDirect
+	// FileDependenciesNone sends only the requested file, which suits
+	// bandwidth-sensitive clients that cache files or only need the requested
+	// one. Clients must ask for its imports themselves.
+	FileDependenciesNone
+)
+
+// String returns "transitive", "direct", or "none".
+func (d FileDependencies) String() string {
+	switch d {
+	case FileDependenciesTransitive:
+		return "transitive"
+	case FileDependenciesDirect:
+		return "direct"
+	case FileDependenciesNone:
+		return "none"
+	}
+	return "unknown"
+}
+
+// maxDepth returns how far from the requested file to follow imports, or -1
+// if there's no limit.
+func (d FileDependencies) maxDepth() int {
+	switch d {
+	case FileDependenciesDirect:
+		return 1
+	case FileDependenciesNone:
+		return 0
+	default:
+		return -1
+	}
+}
+
+// The options below control how files are delivered to clients. Like the
+// limits on streams, they're applied by the Reflector used to build the
+// handler, even for requests routed to other Reflectors by a HostRouter.
+
+// WithFileDependencies sets which imports are sent along with requested files.
+// By default, Reflectors send the full transitive closure of imports.
+func WithFileDependencies(dependencies FileDependencies) Option {
+	return &fileDependenciesOption{dependencies: dependencies}
+}
+
+// WithoutStreamDeduplication makes Reflectors send every file a response
+// calls for, even if it was already sent earlier on the same stream. By
+// default, Reflectors keep track of the files sent on each stream and omit
+// them from later responses (though the requested file itself is always
+// sent). Deduplication saves bandwidth, but some older clients can't handle
+// responses that omit files they've already received.
+func WithoutStreamDeduplication() Option {
+	return &withoutStreamDeduplicationOption{}
+}
+
+type fileDependenciesOption struct {
+	dependencies FileDependencies
+}
+
+func (o *fileDependenciesOption) apply(reflector *Reflector) {
+	reflector.dependencies = o.dependencies
+}
+
+type withoutStreamDeduplicationOption struct{}
+
+func (o *withoutStreamDeduplicationOption) apply(reflector *Reflector) {
+	reflector.noDeduplication = true
+}
@@ -0,0 +1,118 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestFileDependencies(t *testing.T) {
+	t.Parallel()
+	// a.proto imports b.proto, which imports c.proto.
+	files := &protoregistry.Files{}
+	for _, fileProto := range []*descriptorpb.FileDescriptorProto{
+		{Name: proto.String("c.proto"), Package: proto.String("chain")},
+		{Name: proto.String("b.proto"), Package: proto.String("chain"), Dependency: []string{"c.proto"}},
+		{Name: proto.String("a.proto"), Package: proto.String("chain"), Dependency: []string{"b.proto"}},
+	} {
+		registerFile(t, files, fileProto)
+	}
+
+	testCases := []struct {
+		name    string
+		options []Option
+		// The files sent in response to requests for a.proto and then b.proto
+		// on the same stream.
+		expectA []string
+		expectB []string
+	}{
+		{
+			name:    "default",
+			expectA: []string{"a.proto", "b.proto", "c.proto"},
+			expectB: []string{"b.proto"},
+		},
+		{
+			name:    "transitive_without_dedupe",
+			options: []Option{WithFileDependencies(FileDependenciesTransitive), WithoutStreamDeduplication()},
+			expectA: []string{"a.proto", "b.proto", "c.proto"},
+			expectB: []string{"b.proto", "c.proto"},
+		},
+		{
+			name:    "direct",
+			options: []Option{WithFileDependencies(FileDependenciesDirect)},
+			expectA: []string{"a.proto", "b.proto"},
+			expectB: []string{"b.proto", "c.proto"},
+		},
+		{
+			name:    "direct_without_dedupe",
+			options: []Option{WithFileDependencies(FileDependenciesDirect), WithoutStreamDeduplication()},
+			expectA: []string{"a.proto", "b.proto"},
+			expectB: []string{"b.proto", "c.proto"},
+		},
+		{
+			name:    "none",
+			options: []Option{WithFileDependencies(FileDependenciesNone)},
+			expectA: []string{"a.proto"},
+			expectB: []string{"b.proto"},
+		},
+		{
+			name:    "none_without_dedupe",
+			options: []Option{WithFileDependencies(FileDependenciesNone), WithoutStreamDeduplication()},
+			expectA: []string{"a.proto"},
+			expectB: []string{"b.proto"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			options := append([]Option{WithDescriptorResolver(files)}, testCase.options...)
+			reflector := NewReflector(&staticNames{}, options...)
+			stream := newTestStream(t, reflector)
+			for _, request := range []struct {
+				path   string
+				expect []string
+			}{
+				{path: "a.proto", expect: testCase.expectA},
+				{path: "b.proto", expect: testCase.expectB},
+			} {
+				descriptors, err := stream.FileByFilename(request.path)
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				names := make([]string, len(descriptors))
+				for i, descriptor := range descriptors {
+					names[i] = descriptor.GetName()
+				}
+				if !reflect.DeepEqual(request.expect, names) {
+					t.Fatalf("unexpected files for %s: want %v ; got %v", request.path, request.expect, names)
+				}
+			}
+			// ClientResolvers fetch any imports that weren't sent, so they
+			// work with every policy.
+			file, err := NewClientResolver(newTestStream(t, reflector)).FindFileByPath("a.proto")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if imported := file.Imports().Get(0).Imports().Get(0); imported.Path() != "c.proto" || imported.IsPlaceholder() {
+				t.Fatalf("expected c.proto to be resolved, got %v", imported.Path())
+			}
+		})
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// bufImageExtensionNumber is the field number of the buf_extension field in
+// the ImageFile messages of Buf images. Apart from this field, ImageFile is
+// wire-compatible with FileDescriptorProto.
+const bufImageExtensionNumber = 8042
+
+// NewReflectorFromDescriptorSet constructs a Reflector from a serialized
+// FileDescriptorSet, which is convenient for gateways and proxies that don't
+// link in the generated code for the services they front. The data may be a
+// binary or JSON FileDescriptorSet (as produced by "protoc
+// --descriptor_set_out" or "buf build --as-file-descriptor-set"), or a binary
+// or JSON Buf image (as produced by "buf build").
+//
+// The Reflector lists every service in the set, serves the set's files, and
+// resolves extensions defined anywhere in the set. In binary Buf images, files
+// that are only included as imports are served, but their services aren't
+// listed.
+//
+// Files imported by the set but missing from it are looked up in
+// protoregistry.GlobalFiles, which usually contains the well-known types. If
+// any imports still can't be found, NewReflectorFromDescriptorSet returns an
+// error listing them.
+//
+// Any supplied options are applied after the Reflector's resolvers are
+// configured, so they may override them.
+func NewReflectorFromDescriptorSet(data []byte, options ...Option) (*Reflector, error) {
+	fileSet, imports, err := unmarshalDescriptorSet(data)
+	if err != nil {
+		return nil, err
+	}
+	schema, err := newDescriptorSetSchema(fileSet, imports)
+	if err != nil {
+		return nil, err
+	}
+	setOptions := []Option{
+		WithDescriptorResolver(schema.files),
+		WithExtensionResolver(schema.types),
+	}
+	return NewReflector(&staticNames{names: schema.names}, append(setOptions, options...)...), nil
+}
+
+// descriptorSetSchema is everything a Reflector needs to serve the contents of
+// a descriptor set.
+type descriptorSetSchema struct {
+	files *protoregistry.Files
+	types *protoregistry.Types
+	names []string
+}
+
+// newDescriptorSetSchema links and validates the files in the set. Services
+// defined in files whose paths are in imports aren't listed.
+func newDescriptorSetSchema(fileSet *descriptorpb.FileDescriptorSet, imports map[string]struct{}) (*descriptorSetSchema, error) {
+	files, err := newFilesFromDescriptorSet(fileSet)
+	if err != nil {
+		return nil, err
+	}
+	types, err := newExtensionTypes(files)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, fileProto := range fileSet.GetFile() {
+		if _, ok := imports[fileProto.GetName()]; ok {
+			continue
+		}
+		pkg := fileProto.GetPackage()
+		for _, service := range fileProto.GetService() {
+			if pkg == "" {
+				names = append(names, service.GetName())
+			} else {
+				names = append(names, pkg+"."+service.GetName())
+			}
+		}
+	}
+	sort.Strings(names)
+	return &descriptorSetSchema{files: files, types: types, names: names}, nil
+}
+
+// unmarshalDescriptorSet decodes a binary or JSON FileDescriptorSet or Buf
+// image. It also returns the paths of the files that a binary Buf image marks
+// as imports.
+func unmarshalDescriptorSet(data []byte) (*descriptorpb.FileDescriptorSet, map[string]struct{}, error) {
+	fileSet := &descriptorpb.FileDescriptorSet{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		// Buf images in JSON have extra fields, which we ignore.
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(trimmed, fileSet); err != nil {
+			return nil, nil, fmt.Errorf("invalid JSON descriptor set: %w", err)
+		}
+		return fileSet, nil, nil
+	}
+	if err := proto.Unmarshal(data, fileSet); err != nil {
+		return nil, nil, fmt.Errorf("invalid descriptor set: %w", err)
+	}
+	imports := make(map[string]struct{})
+	for _, fileProto := range fileSet.GetFile() {
+		if isBufImageImport(fileProto) {
+			imports[fileProto.GetName()] = struct{}{}
+		}
+	}
+	return fileSet, imports, nil
+}
+
+// isBufImageImport reports whether the file's buf_extension field marks it as
+// an import. It's only set for files in Buf images.
+func isBufImageImport(fileProto *descriptorpb.FileDescriptorProto) bool {
+	unknown := fileProto.ProtoReflect().GetUnknown()
+	for len(unknown) > 0 {
+		num, typ, length := protowire.ConsumeField(unknown)
+		if length < 0 {
+			return false
+		}
+		if num == bufImageExtensionNumber && typ == protowire.BytesType {
+			ext, _ := protowire.ConsumeBytes(unknown[protowire.SizeTag(num):])
+			// The extension's is_import field is a bool with number 1.
+			for len(ext) > 0 {
+				extNum, extTyp, extLength := protowire.ConsumeField(ext)
+				if extLength < 0 {
+					return false
+				}
+				if extNum == 1 && extTyp == protowire.VarintType {
+					value, _ := protowire.ConsumeVarint(ext[protowire.SizeTag(extNum):])
+					return value != 0
+				}
+				ext = ext[extLength:]
+			}
+		}
+		unknown = unknown[length:]
+	}
+	return false
+}
+
+// newFilesFromDescriptorSet links the files in the set, looking up imports
+// that aren't in the set in protoregistry.GlobalFiles.
+func newFilesFromDescriptorSet(fileSet *descriptorpb.FileDescriptorSet) (*protoregistry.Files, error) {
+	protos := make(map[string]*descriptorpb.FileDescriptorProto, len(fileSet.GetFile()))
+	for _, fileProto := range fileSet.GetFile() {
+		if _, ok := protos[fileProto.GetName()]; ok {
+			return nil, fmt.Errorf("descriptor set contains %q more than once", fileProto.GetName())
+		}
+		protos[fileProto.GetName()] = fileProto
+	}
+	var missing []string
+	for _, fileProto := range fileSet.GetFile() {
+		for _, dep := range fileProto.GetDependency() {
+			if _, ok := protos[dep]; ok {
+				continue
+			}
+			if _, err := protoregistry.GlobalFiles.FindFileByPath(dep); err == nil {
+				continue
+			}
+			missing = append(missing, fmt.Sprintf("%q (imported by %q)", dep, fileProto.GetName()))
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("descriptor set is missing imports: %s", strings.Join(missing, ", "))
+	}
+	linker := &descriptorSetLinker{
+		protos:  protos,
+		files:   &protoregistry.Files{},
+		linking: make(map[string]struct{}),
+	}
+	for _, fileProto := range fileSet.GetFile() {
+		if err := linker.link(fileProto.GetName()); err != nil {
+			return nil, err
+		}
+	}
+	return linker.files, nil
+}
+
+type descriptorSetLinker struct {
+	protos  map[string]*descriptorpb.FileDescriptorProto
+	files   *protoregistry.Files
+	linking map[string]struct{}
+}
+
+// link registers the file with the given path, after registering its imports.
+func (l *descriptorSetLinker) link(path string) error {
+	if _, err := l.files.FindFileByPath(path); err == nil {
+		return nil
+	}
+	fileProto, ok := l.protos[path]
+	if !ok {
+		// We've already checked that the file is in GlobalFiles.
+		file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+		if err != nil {
+			return err
+		}
+		imports := file.Imports()
+		for i := range imports.Len() {
+			if err := l.link(imports.Get(i).Path()); err != nil {
+				return err
+			}
+		}
+		return l.files.RegisterFile(file)
+	}
+	if _, ok := l.linking[path]; ok {
+		return fmt.Errorf("import cycle in descriptor set involving %q", path)
+	}
+	l.linking[path] = struct{}{}
+	for _, dep := range fileProto.GetDependency() {
+		if err := l.link(dep); err != nil {
+			return err
+		}
+	}
+	delete(l.linking, path)
+	// Buf images use an unknown field for their own metadata, which we don't
+	// want to pass along.
+	clone, _ := proto.Clone(fileProto).(*descriptorpb.FileDescriptorProto)
+	clone.ProtoReflect().SetUnknown(nil)
+	file, err := protodesc.NewFile(clone, l.files)
+	if err != nil {
+		return fmt.Errorf("invalid file %q in descriptor set: %w", path, err)
+	}
+	return l.files.RegisterFile(file)
+}
+
+// newExtensionTypes creates dynamic types for every extension in the files.
+func newExtensionTypes(files *protoregistry.Files) (*protoregistry.Types, error) {
+	types := &protoregistry.Types{}
+	var err error
+	rangeExtensions(files, func(ext protoreflect.ExtensionDescriptor) bool {
+		err = types.RegisterExtension(dynamicpb.NewExtensionType(ext))
+		return err == nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid extension in descriptor set: %w", err)
+	}
+	return types, nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"reflect"
+	"testing"
+
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestNewReflectorFromDescriptorSet(t *testing.T) {
+	t.Parallel()
+	marshal := func(t *testing.T, msg proto.Message) []byte {
+		t.Helper()
+		data, err := proto.Marshal(msg)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return data
+	}
+	allServices := []protoreflect.FullName{"acme.v1.DescribeService", "connect.reflecttest.v1.TestService"}
+
+	testCases := []struct {
+		name     string
+		data     func(t *testing.T) []byte
+		expected []protoreflect.FullName
+	}{
+		{
+			name: "binary",
+			data: func(t *testing.T) []byte {
+				t.Helper()
+				return marshal(t, newTestFileSet(t))
+			},
+			expected: allServices,
+		},
+		{
+			name: "json",
+			data: func(t *testing.T) []byte {
+				t.Helper()
+				data, err := protojson.Marshal(newTestFileSet(t))
+				if err != nil {
+					t.Fatalf("unexpected err: %v", err)
+				}
+				return data
+			},
+			expected: allServices,
+		},
+		{
+			name: "buf_image",
+			data: func(t *testing.T) []byte {
+				t.Helper()
+				fileSet := newTestFileSet(t)
+				// Mark the reflecttest files as imports, as buf does for files
+				// from dependencies.
+				var isImport []byte
+				isImport = protowire.AppendTag(isImport, 1, protowire.VarintType)
+				isImport = protowire.AppendVarint(isImport, 1)
+				var ext []byte
+				ext = protowire.AppendTag(ext, bufImageExtensionNumber, protowire.BytesType)
+				ext = protowire.AppendBytes(ext, isImport)
+				for _, file := range fileSet.GetFile()[:2] {
+					file.ProtoReflect().SetUnknown(ext)
+				}
+				return marshal(t, fileSet)
+			},
+			expected: []protoreflect.FullName{"acme.v1.DescribeService"},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			reflector, err := NewReflectorFromDescriptorSet(testCase.data(t))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			stream := newTestStream(t, reflector)
+			names, err := stream.ListServices()
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if !reflect.DeepEqual(testCase.expected, names) {
+				t.Fatalf("unexpected service names: want %v ; got %v", testCase.expected, names)
+			}
+			files, err := stream.FileContainingSymbol("acme.v1.DescribeService")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(files) != 2 || files[1].GetName() != "google/protobuf/descriptor.proto" {
+				t.Fatalf("expected file and its import, got %d files", len(files))
+			}
+			if files[0].ProtoReflect().GetUnknown() != nil {
+				t.Fatal("expected buf image metadata to be removed")
+			}
+			numbers, err := stream.AllExtensionNumbers("connect.reflecttest.v1.Extendable")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if expected := []protoreflect.FieldNumber{10, 11}; !reflect.DeepEqual(expected, numbers) {
+				t.Fatalf("unexpected extension numbers: want %v ; got %v", expected, numbers)
+			}
+			if _, err := stream.FileContainingExtension("connect.reflecttest.v1.Extendable", 10); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		})
+	}
+
+	t.Run("missing_imports", func(t *testing.T) {
+		t.Parallel()
+		// reflecttest.proto is linked into this binary, so removing it from the
+		// set doesn't make it missing.
+		fileSet := newTestFileSet(t)
+		fileSet.File = fileSet.GetFile()[1:]
+		fileSet.File[0].Dependency = append(fileSet.File[0].Dependency, "acme/v1/gone.proto")
+		_, err := NewReflectorFromDescriptorSet(marshal(t, fileSet))
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		expected := `descriptor set is missing imports: "acme/v1/gone.proto" (imported by "connect/reflecttest/v1/reflecttest_ext.proto")`
+		if err.Error() != expected {
+			t.Fatalf("unexpected error: want %q ; got %q", expected, err.Error())
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		for _, data := range []string{"{not json", "\xff\xff\xff"} {
+			if _, err := NewReflectorFromDescriptorSet([]byte(data)); err == nil {
+				t.Errorf("expected error for %q", data)
+			}
+		}
+	})
+}
+
+// newTestFileSet returns a descriptor set with the reflecttest files and a
+// file that imports descriptor.proto, which isn't in the set.
+func newTestFileSet(tb testing.TB) *descriptorpb.FileDescriptorSet {
+	tb.Helper()
+	fileSet := &descriptorpb.FileDescriptorSet{}
+	for _, path := range []string{
+		"connect/reflecttest/v1/reflecttest.proto",
+		"connect/reflecttest/v1/reflecttest_ext.proto",
+	} {
+		file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+		if err != nil {
+			tb.Fatalf("unexpected err: %v", err)
+		}
+		fileSet.File = append(fileSet.File, protodesc.ToFileDescriptorProto(file))
+	}
+	fileSet.File = append(fileSet.File, &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("acme/v1/describe.proto"),
+		Package:    proto.String("acme.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("DescribeService"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("Describe"),
+				InputType:  proto.String(".google.protobuf.FileDescriptorSet"),
+				OutputType: proto.String(".google.protobuf.FileDescriptorSet"),
+			}},
+		}},
+	})
+	return fileSet
+}
@@ -0,0 +1,411 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"fmt"
+	"strings"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// WithDownleveledEditions rewrites files that use Protobuf Editions as
+// equivalent proto3 or proto2 files before they're sent to clients, for the
+// benefit of older clients that don't understand editions. Resolved features
+// are expressed with the older syntax's keywords and options: explicit
+// presence becomes "optional" (or a required label, for legacy required
+// fields), delimited encoding becomes a group, and so on. Proto3 is preferred
+// when both would work.
+//
+// Some files can't be rewritten without changing their meaning: for example,
+// a file that mixes required fields (which proto3 doesn't have) with fields
+// that have implicit presence (which proto2 doesn't have). Such files are sent
+// unchanged, and report is called with a *DownlevelError explaining why, if
+// it's not nil. Since encoded files are cached, report is usually called once
+// per file. Use DownlevelEditions to check files ahead of time.
+func WithDownleveledEditions(report func(*DownlevelError)) Option {
+	return &downleveledEditionsOption{report: report}
+}
+
+// DownlevelError reports that a file using Protobuf Editions can't be
+// rewritten as proto3 or proto2 without changing its meaning.
+type DownlevelError struct {
+	// Path is the path of the file.
+	Path string
+	// Proto3 and Proto2 describe the first feature found in the file that the
+	// corresponding syntax can't express.
+	Proto3 string
+	Proto2 string
+}
+
+func (e *DownlevelError) Error() string {
+	return fmt.Sprintf("can't downlevel %q from editions: proto3: %s; proto2: %s", e.Path, e.Proto3, e.Proto2)
+}
+
+// DownlevelEditions returns the file as a FileDescriptorProto. If the file
+// uses Protobuf Editions, it's rewritten as an equivalent proto3 or proto2
+// file, as described in WithDownleveledEditions; if that's impossible,
+// DownlevelEditions returns a *DownlevelError. Files that don't use editions
+// are returned unchanged.
+func DownlevelEditions(file protoreflect.FileDescriptor) (*descriptorpb.FileDescriptorProto, error) {
+	fileProto := protodesc.ToFileDescriptorProto(file)
+	if err := downlevelFileProto(file, fileProto); err != nil {
+		return nil, err
+	}
+	return fileProto, nil
+}
+
+// downlevelFileProto rewrites fileProto, which must describe file, if file
+// uses editions. If that's impossible, it returns a *DownlevelError and leaves
+// fileProto unchanged.
+func downlevelFileProto(file protoreflect.FileDescriptor, fileProto *descriptorpb.FileDescriptorProto) *DownlevelError {
+	if file.Syntax() != protoreflect.Editions {
+		return nil
+	}
+	problem3 := findDownlevelProblem(file, proto3Problem)
+	if problem3 == "" {
+		rewriteFile(file, fileProto, protoreflect.Proto3)
+		return nil
+	}
+	problem2 := findDownlevelProblem(file, proto2Problem)
+	if problem2 == "" {
+		rewriteFile(file, fileProto, protoreflect.Proto2)
+		return nil
+	}
+	return &DownlevelError{Path: file.Path(), Proto3: problem3, Proto2: problem2}
+}
+
+// findDownlevelProblem calls check with each enum, message, and field
+// (including extensions) in the file, returning the first problem it reports.
+func findDownlevelProblem(file protoreflect.FileDescriptor, check func(protoreflect.Descriptor) string) string {
+	var problem string
+	checkAll := func(desc protoreflect.Descriptor) bool {
+		problem = check(desc)
+		return problem == ""
+	}
+	rangeFileElements(file, checkAll)
+	return problem
+}
+
+// rangeFileElements calls f with each enum, message, and field (including
+// extensions) in the file, until f returns false.
+func rangeFileElements(file protoreflect.FileDescriptor, f func(protoreflect.Descriptor) bool) {
+	var rangeScope func(enums protoreflect.EnumDescriptors, messages protoreflect.MessageDescriptors, extensions protoreflect.ExtensionDescriptors) bool
+	rangeScope = func(enums protoreflect.EnumDescriptors, messages protoreflect.MessageDescriptors, extensions protoreflect.ExtensionDescriptors) bool {
+		for i := range enums.Len() {
+			if !f(enums.Get(i)) {
+				return false
+			}
+		}
+		for i := range extensions.Len() {
+			if !f(extensions.Get(i)) {
+				return false
+			}
+		}
+		for i := range messages.Len() {
+			message := messages.Get(i)
+			if !f(message) {
+				return false
+			}
+			fields := message.Fields()
+			for j := range fields.Len() {
+				if !f(fields.Get(j)) {
+					return false
+				}
+			}
+			if !rangeScope(message.Enums(), message.Messages(), message.Extensions()) {
+				return false
+			}
+		}
+		return true
+	}
+	rangeScope(file.Enums(), file.Messages(), file.Extensions())
+}
+
+// proto3Problem describes why proto3 can't express the element, or returns
+// an empty string if it can.
+func proto3Problem(desc protoreflect.Descriptor) string {
+	switch desc := desc.(type) {
+	case protoreflect.EnumDescriptor:
+		if desc.IsClosed() {
+			return fmt.Sprintf("enum %s is closed", desc.FullName())
+		}
+	case protoreflect.MessageDescriptor:
+		if desc.ExtensionRanges().Len() > 0 {
+			return fmt.Sprintf("message %s has extension ranges", desc.FullName())
+		}
+	case protoreflect.FieldDescriptor:
+		switch {
+		case desc.IsExtension() && !isOptionsMessage(desc.ContainingMessage()):
+			return fmt.Sprintf("extension %s extends %s, which isn't an options message", desc.FullName(), desc.ContainingMessage().FullName())
+		case desc.Cardinality() == protoreflect.Required:
+			return fmt.Sprintf("field %s is required", desc.FullName())
+		case desc.Kind() == protoreflect.GroupKind:
+			return fmt.Sprintf("field %s uses delimited encoding", desc.FullName())
+		case desc.HasDefault():
+			return fmt.Sprintf("field %s has a default value", desc.FullName())
+		case desc.Enum() != nil && desc.Enum().IsClosed():
+			return fmt.Sprintf("field %s uses closed enum %s", desc.FullName(), desc.Enum().FullName())
+		case desc.Kind() == protoreflect.StringKind && !validatesUTF8(desc):
+			return fmt.Sprintf("field %s doesn't validate UTF-8", desc.FullName())
+		}
+	}
+	return ""
+}
+
+// proto2Problem describes why proto2 can't express the element, or returns
+// an empty string if it can.
+func proto2Problem(desc protoreflect.Descriptor) string {
+	switch desc := desc.(type) {
+	case protoreflect.EnumDescriptor:
+		if !desc.IsClosed() {
+			return fmt.Sprintf("enum %s is open", desc.FullName())
+		}
+	case protoreflect.FieldDescriptor:
+		switch {
+		case desc.Cardinality() == protoreflect.Optional && !desc.HasPresence() && !isMapEntryField(desc):
+			return fmt.Sprintf("field %s has implicit presence", desc.FullName())
+		case desc.Kind() == protoreflect.GroupKind && !isGroupLike(desc):
+			return fmt.Sprintf("field %s uses delimited encoding, but isn't shaped like a group", desc.FullName())
+		case desc.Kind() == protoreflect.StringKind && validatesUTF8(desc):
+			return fmt.Sprintf("field %s validates UTF-8", desc.FullName())
+		}
+	}
+	return ""
+}
+
+// rewriteFile rewrites fileProto in the given syntax. The caller must have
+// checked that the syntax can express the file.
+func rewriteFile(file protoreflect.FileDescriptor, fileProto *descriptorpb.FileDescriptorProto, syntax protoreflect.Syntax) {
+	fileProto.Syntax = proto.String(syntax.String())
+	fileProto.Edition = nil
+	for i, ext := range fileProto.GetExtension() {
+		rewriteField(file.Extensions().Get(i), ext, syntax)
+	}
+	for i, message := range fileProto.GetMessageType() {
+		rewriteMessage(file.Messages().Get(i), message, syntax)
+	}
+	clearFeatures(fileProto.ProtoReflect())
+}
+
+func rewriteMessage(message protoreflect.MessageDescriptor, messageProto *descriptorpb.DescriptorProto, syntax protoreflect.Syntax) {
+	for i, ext := range messageProto.GetExtension() {
+		rewriteField(message.Extensions().Get(i), ext, syntax)
+	}
+	for i, nested := range messageProto.GetNestedType() {
+		rewriteMessage(message.Messages().Get(i), nested, syntax)
+	}
+	if message.IsMapEntry() {
+		return
+	}
+	for i, field := range messageProto.GetField() {
+		rewriteField(message.Fields().Get(i), field, syntax)
+	}
+	if syntax == protoreflect.Proto3 {
+		addSyntheticOneofs(message, messageProto)
+	}
+}
+
+func rewriteField(field protoreflect.FieldDescriptor, fieldProto *descriptorpb.FieldDescriptorProto, syntax protoreflect.Syntax) {
+	if field.Cardinality() == protoreflect.Required {
+		fieldProto.Label = descriptorpb.FieldDescriptorProto_LABEL_REQUIRED.Enum()
+	}
+	if field.Kind() == protoreflect.GroupKind {
+		fieldProto.Type = descriptorpb.FieldDescriptorProto_TYPE_GROUP.Enum()
+	}
+	if field.Cardinality() != protoreflect.Repeated || !isPackable(field.Kind()) {
+		return
+	}
+	// Repeated scalars are packed by default in proto3, but not in proto2.
+	if packed := field.IsPacked(); packed != (syntax == protoreflect.Proto3) {
+		if fieldProto.Options == nil {
+			fieldProto.Options = &descriptorpb.FieldOptions{}
+		}
+		fieldProto.Options.Packed = proto.Bool(packed)
+	}
+}
+
+// addSyntheticOneofs marks scalar fields with explicit presence as proto3
+// optional fields, adding the synthetic oneofs that protoc would.
+func addSyntheticOneofs(message protoreflect.MessageDescriptor, messageProto *descriptorpb.DescriptorProto) {
+	taken := make(map[string]struct{})
+	for _, field := range messageProto.GetField() {
+		taken[field.GetName()] = struct{}{}
+	}
+	for _, oneof := range messageProto.GetOneofDecl() {
+		taken[oneof.GetName()] = struct{}{}
+	}
+	for _, nested := range messageProto.GetNestedType() {
+		taken[nested.GetName()] = struct{}{}
+	}
+	for _, enum := range messageProto.GetEnumType() {
+		taken[enum.GetName()] = struct{}{}
+	}
+	for i, fieldProto := range messageProto.GetField() {
+		field := message.Fields().Get(i)
+		if field.Cardinality() != protoreflect.Optional || field.Message() != nil ||
+			field.ContainingOneof() != nil || !field.HasPresence() {
+			continue
+		}
+		// Like protoc, prefix the field name with an underscore, then add Xs
+		// until it's unique.
+		name := "_" + fieldProto.GetName()
+		for {
+			if _, ok := taken[name]; !ok {
+				break
+			}
+			name = "X" + name
+		}
+		taken[name] = struct{}{}
+		fieldProto.Proto3Optional = proto.Bool(true)
+		fieldProto.OneofIndex = proto.Int32(int32(len(messageProto.GetOneofDecl())))
+		messageProto.OneofDecl = append(messageProto.OneofDecl, &descriptorpb.OneofDescriptorProto{
+			Name: proto.String(name),
+		})
+	}
+}
+
+// clearFeatures removes the features from every options message in the
+// descriptor, removing options messages that are left empty.
+func clearFeatures(msg protoreflect.Message) {
+	var emptyOptions []protoreflect.FieldDescriptor
+	msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Message() == nil || field.IsExtension() {
+			return true
+		}
+		if field.Name() == "options" {
+			options := value.Message()
+			if features := options.Descriptor().Fields().ByName("features"); features != nil {
+				options.Clear(features)
+			}
+			if isEmptyMessage(options) {
+				emptyOptions = append(emptyOptions, field)
+			}
+			return true
+		}
+		if field.IsList() {
+			list := value.List()
+			for i := range list.Len() {
+				clearFeatures(list.Get(i).Message())
+			}
+			return true
+		}
+		clearFeatures(value.Message())
+		return true
+	})
+	for _, field := range emptyOptions {
+		msg.Clear(field)
+	}
+}
+
+func isEmptyMessage(msg protoreflect.Message) bool {
+	empty := len(msg.GetUnknown()) == 0
+	msg.Range(func(protoreflect.FieldDescriptor, protoreflect.Value) bool {
+		empty = false
+		return false
+	})
+	return empty
+}
+
+// validatesUTF8 reports whether the string field's resolved utf8_validation
+// feature is VERIFY, which is the default in every edition.
+func validatesUTF8(field protoreflect.FieldDescriptor) bool {
+	var desc protoreflect.Descriptor = field
+	for desc != nil {
+		if validation := featuresOf(desc).GetUtf8Validation(); validation != descriptorpb.FeatureSet_UTF8_VALIDATION_UNKNOWN {
+			return validation == descriptorpb.FeatureSet_VERIFY
+		}
+		// Map entries inherit features from their map fields.
+		if parent, ok := desc.Parent().(protoreflect.MessageDescriptor); ok && parent.IsMapEntry() {
+			if mapField := findMapField(parent); mapField != nil {
+				desc = mapField
+				continue
+			}
+		}
+		desc = desc.Parent()
+	}
+	return true
+}
+
+// featuresOf returns the features set explicitly in the descriptor's options,
+// or nil if there aren't any.
+func featuresOf(desc protoreflect.Descriptor) *descriptorpb.FeatureSet {
+	options := desc.Options()
+	if options == nil {
+		return nil
+	}
+	msg := options.ProtoReflect()
+	field := msg.Descriptor().Fields().ByName("features")
+	if field == nil || !msg.Has(field) {
+		return nil
+	}
+	features, _ := msg.Get(field).Message().Interface().(*descriptorpb.FeatureSet)
+	return features
+}
+
+// findMapField returns the field that uses the map entry message.
+func findMapField(entry protoreflect.MessageDescriptor) protoreflect.FieldDescriptor {
+	parent, ok := entry.Parent().(protoreflect.MessageDescriptor)
+	if !ok {
+		return nil
+	}
+	fields := parent.Fields()
+	for i := range fields.Len() {
+		if fields.Get(i).Message() == entry {
+			return fields.Get(i)
+		}
+	}
+	return nil
+}
+
+func isMapEntryField(field protoreflect.FieldDescriptor) bool {
+	parent, ok := field.Parent().(protoreflect.MessageDescriptor)
+	return ok && parent.IsMapEntry()
+}
+
+// isGroupLike reports whether a delimited field can be written as a proto2
+// group: the message must be declared next to the field, and the field's name
+// must be the message's name in lowercase.
+func isGroupLike(field protoreflect.FieldDescriptor) bool {
+	message := field.Message()
+	return message != nil &&
+		message.Parent() == field.Parent() &&
+		string(field.Name()) == strings.ToLower(string(message.Name()))
+}
+
+func isOptionsMessage(message protoreflect.MessageDescriptor) bool {
+	name := string(message.FullName())
+	return strings.HasPrefix(name, "google.protobuf.") && strings.HasSuffix(name, "Options")
+}
+
+func isPackable(kind protoreflect.Kind) bool {
+	switch kind {
+	case protoreflect.StringKind, protoreflect.BytesKind, protoreflect.MessageKind, protoreflect.GroupKind:
+		return false
+	}
+	return true
+}
+
+type downleveledEditionsOption struct {
+	report func(*DownlevelError)
+}
+
+func (o *downleveledEditionsOption) apply(reflector *Reflector) {
+	reflector.downlevelEditions = true
+	reflector.reportDownlevel = o.report
+}
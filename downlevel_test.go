@@ -0,0 +1,338 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDownlevelEditions(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name   string
+		file   *descriptorpb.FileDescriptorProto
+		syntax string
+		check  func(t *testing.T, fileProto *descriptorpb.FileDescriptorProto)
+	}{
+		{
+			name:   "proto3",
+			file:   newProto3LikeEditionsFile(),
+			syntax: "proto3",
+			check: func(t *testing.T, fileProto *descriptorpb.FileDescriptorProto) {
+				t.Helper()
+				message := fileProto.GetMessageType()[0]
+				name := message.GetField()[0]
+				if !name.GetProto3Optional() || message.GetOneofDecl()[name.GetOneofIndex()].GetName() != "X_name" {
+					t.Errorf("expected explicit presence to become a proto3 optional field: %v", name)
+				}
+				if implicit := message.GetField()[1]; implicit.Proto3Optional != nil || implicit.OneofIndex != nil {
+					t.Errorf("expected implicit presence to become a plain field: %v", implicit)
+				}
+				if expanded := message.GetField()[3]; expanded.GetOptions().Packed == nil || expanded.GetOptions().GetPacked() {
+					t.Errorf("expected expanded field to be marked as unpacked: %v", expanded)
+				}
+				if packed := message.GetField()[2]; packed.GetOptions() != nil {
+					t.Errorf("expected packed field to have no options: %v", packed)
+				}
+			},
+		},
+		{
+			name:   "proto2",
+			file:   newProto2LikeEditionsFile(),
+			syntax: "proto2",
+			check: func(t *testing.T, fileProto *descriptorpb.FileDescriptorProto) {
+				t.Helper()
+				fields := fileProto.GetMessageType()[0].GetField()
+				if fields[0].GetLabel() != descriptorpb.FieldDescriptorProto_LABEL_REQUIRED {
+					t.Errorf("expected legacy required field to become required: %v", fields[0])
+				}
+				if fields[1].GetType() != descriptorpb.FieldDescriptorProto_TYPE_GROUP {
+					t.Errorf("expected delimited field to become a group: %v", fields[1])
+				}
+				if !fields[2].GetOptions().GetPacked() {
+					t.Errorf("expected packed field to be marked as packed: %v", fields[2])
+				}
+				if fields[3].GetDefaultValue() != "B" {
+					t.Errorf("expected default value to be kept: %v", fields[3])
+				}
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			file, err := protodesc.NewFile(testCase.file, protoregistry.GlobalFiles)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			downleveled, err := DownlevelEditions(file)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if downleveled.GetSyntax() != testCase.syntax || downleveled.Edition != nil {
+				t.Fatalf("expected syntax %s, got syntax %q and edition %v", testCase.syntax, downleveled.GetSyntax(), downleveled.Edition)
+			}
+			rangeOptions(downleveled.ProtoReflect(), func(options protoreflect.Message) {
+				if features := options.Descriptor().Fields().ByName("features"); options.Has(features) {
+					t.Errorf("expected features to be removed from %s", options.Descriptor().FullName())
+				}
+			})
+			testCase.check(t, downleveled)
+			// The downleveled file must mean the same thing as the original.
+			relinked, err := protodesc.NewFile(downleveled, protoregistry.GlobalFiles)
+			if err != nil {
+				t.Fatalf("downleveled file is invalid: %v", err)
+			}
+			checkSameSemantics(t, file, relinked)
+		})
+	}
+
+	t.Run("impossible", func(t *testing.T) {
+		t.Parallel()
+		fileProto := newProto2LikeEditionsFile()
+		// Implicit presence rules out proto2, and the closed enum rules out
+		// proto3.
+		message := fileProto.GetMessageType()[0]
+		message.Field = append(message.Field, newEditionsField("extra", 5, descriptorpb.FieldDescriptorProto_TYPE_INT32, &descriptorpb.FeatureSet{
+			FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum(),
+		}))
+		file, err := protodesc.NewFile(fileProto, protoregistry.GlobalFiles)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, err = DownlevelEditions(file)
+		var downlevelErr *DownlevelError
+		if !errors.As(err, &downlevelErr) {
+			t.Fatalf("expected DownlevelError, got %v", err)
+		}
+		expected := DownlevelError{
+			Path:   "downlevel/proto2.proto",
+			Proto3: "enum downlevel.Letter is closed",
+			Proto2: "field downlevel.Legacy.extra has implicit presence",
+		}
+		if *downlevelErr != expected {
+			t.Fatalf("unexpected error: want %+v ; got %+v", expected, *downlevelErr)
+		}
+	})
+	t.Run("not_editions", func(t *testing.T) {
+		t.Parallel()
+		file, err := protoregistry.GlobalFiles.FindFileByPath("connect/reflecttest/v1/reflecttest.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		downleveled, err := DownlevelEditions(file)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !proto.Equal(protodesc.ToFileDescriptorProto(file), downleveled) {
+			t.Fatal("expected file to be unchanged")
+		}
+	})
+}
+
+func TestWithDownleveledEditions(t *testing.T) {
+	t.Parallel()
+	files := &protoregistry.Files{}
+	registerFile(t, files, newProto3LikeEditionsFile())
+	impossible := newProto3LikeEditionsFile()
+	impossible.Name = proto.String("downlevel/impossible.proto")
+	impossible.Package = proto.String("impossible")
+	impossible.GetMessageType()[0].GetField()[0].Options = &descriptorpb.FieldOptions{
+		Features: &descriptorpb.FeatureSet{FieldPresence: descriptorpb.FeatureSet_LEGACY_REQUIRED.Enum()},
+	}
+	registerFile(t, files, impossible)
+
+	var mu sync.Mutex
+	var reported []string
+	reflector := NewReflector(
+		&staticNames{},
+		WithDescriptorResolver(files),
+		WithDownleveledEditions(func(err *DownlevelError) {
+			mu.Lock()
+			defer mu.Unlock()
+			reported = append(reported, err.Path)
+		}),
+	)
+	stream := newTestStream(t, reflector)
+
+	descriptors, err := stream.FileByFilename("downlevel/proto3.proto")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if descriptors[0].GetSyntax() != "proto3" {
+		t.Fatalf("expected proto3 file, got syntax %q", descriptors[0].GetSyntax())
+	}
+	descriptors, err = stream.FileByFilename("downlevel/impossible.proto")
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if descriptors[0].GetSyntax() != "editions" || descriptors[0].GetEdition() != descriptorpb.Edition_EDITION_2023 {
+		t.Fatalf("expected file to be sent unchanged, got syntax %q", descriptors[0].GetSyntax())
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || reported[0] != "downlevel/impossible.proto" {
+		t.Fatalf("unexpected reports: %v", reported)
+	}
+}
+
+// newProto3LikeEditionsFile returns an editions file that proto3 can express.
+// Its message has a string field with explicit presence, int32 fields with
+// implicit presence (one of which has the name protoc would normally give the
+// synthetic oneof), and packed and expanded repeated fields.
+func newProto3LikeEditionsFile() *descriptorpb.FileDescriptorProto {
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("downlevel/proto3.proto"),
+		Package: proto.String("downlevel"),
+		Syntax:  proto.String("editions"),
+		Edition: descriptorpb.Edition_EDITION_2023.Enum(),
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Modern"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				newEditionsField("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, nil),
+				newEditionsField("count", 2, descriptorpb.FieldDescriptorProto_TYPE_INT32, &descriptorpb.FeatureSet{
+					FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum(),
+				}),
+				newRepeatedField(newEditionsField("packed", 3, descriptorpb.FieldDescriptorProto_TYPE_INT32, nil)),
+				newRepeatedField(newEditionsField("expanded", 4, descriptorpb.FieldDescriptorProto_TYPE_INT32, &descriptorpb.FeatureSet{
+					RepeatedFieldEncoding: descriptorpb.FeatureSet_EXPANDED.Enum(),
+				})),
+				newEditionsField("_name", 5, descriptorpb.FieldDescriptorProto_TYPE_INT32, &descriptorpb.FeatureSet{
+					FieldPresence: descriptorpb.FeatureSet_IMPLICIT.Enum(),
+				}),
+			},
+		}},
+	}
+}
+
+// newProto2LikeEditionsFile returns an editions file that proto2 can express.
+// Like proto2 files migrated to editions, it has closed enums and no UTF-8
+// validation. Its message has a legacy required field, a delimited field
+// shaped like a group, a packed repeated field, and an enum field with a
+// default.
+func newProto2LikeEditionsFile() *descriptorpb.FileDescriptorProto {
+	enumField := newEditionsField("letter", 4, descriptorpb.FieldDescriptorProto_TYPE_ENUM, nil)
+	enumField.TypeName = proto.String(".downlevel.Letter")
+	enumField.DefaultValue = proto.String("B")
+	groupField := newEditionsField("item", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, &descriptorpb.FeatureSet{
+		MessageEncoding: descriptorpb.FeatureSet_DELIMITED.Enum(),
+	})
+	groupField.TypeName = proto.String(".downlevel.Legacy.Item")
+	return &descriptorpb.FileDescriptorProto{
+		Name:    proto.String("downlevel/proto2.proto"),
+		Package: proto.String("downlevel"),
+		Syntax:  proto.String("editions"),
+		Edition: descriptorpb.Edition_EDITION_2023.Enum(),
+		Options: &descriptorpb.FileOptions{
+			Features: &descriptorpb.FeatureSet{
+				EnumType:              descriptorpb.FeatureSet_CLOSED.Enum(),
+				Utf8Validation:        descriptorpb.FeatureSet_NONE.Enum(),
+				RepeatedFieldEncoding: descriptorpb.FeatureSet_EXPANDED.Enum(),
+			},
+		},
+		EnumType: []*descriptorpb.EnumDescriptorProto{{
+			Name: proto.String("Letter"),
+			Value: []*descriptorpb.EnumValueDescriptorProto{
+				{Name: proto.String("A"), Number: proto.Int32(1)},
+				{Name: proto.String("B"), Number: proto.Int32(2)},
+			},
+		}},
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name: proto.String("Legacy"),
+			Field: []*descriptorpb.FieldDescriptorProto{
+				newEditionsField("id", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, &descriptorpb.FeatureSet{
+					FieldPresence: descriptorpb.FeatureSet_LEGACY_REQUIRED.Enum(),
+				}),
+				groupField,
+				newRepeatedField(newEditionsField("packed", 3, descriptorpb.FieldDescriptorProto_TYPE_INT64, &descriptorpb.FeatureSet{
+					RepeatedFieldEncoding: descriptorpb.FeatureSet_PACKED.Enum(),
+				})),
+				enumField,
+			},
+			NestedType: []*descriptorpb.DescriptorProto{{Name: proto.String("Item")}},
+		}},
+	}
+}
+
+func newEditionsField(name string, number int32, typ descriptorpb.FieldDescriptorProto_Type, features *descriptorpb.FeatureSet) *descriptorpb.FieldDescriptorProto {
+	field := &descriptorpb.FieldDescriptorProto{
+		Name:   proto.String(name),
+		Number: proto.Int32(number),
+		Label:  descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+		Type:   typ.Enum(),
+	}
+	if features != nil {
+		field.Options = &descriptorpb.FieldOptions{Features: features}
+	}
+	return field
+}
+
+func newRepeatedField(field *descriptorpb.FieldDescriptorProto) *descriptorpb.FieldDescriptorProto {
+	field.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	return field
+}
+
+// checkSameSemantics checks that the fields and enums of two versions of a
+// file behave the same way.
+func checkSameSemantics(t *testing.T, want, got protoreflect.FileDescriptor) {
+	t.Helper()
+	var wantElements []protoreflect.Descriptor
+	rangeFileElements(want, func(desc protoreflect.Descriptor) bool {
+		wantElements = append(wantElements, desc)
+		return true
+	})
+	i := 0
+	rangeFileElements(got, func(gotDesc protoreflect.Descriptor) bool {
+		if i >= len(wantElements) {
+			t.Errorf("unexpected element %s", gotDesc.FullName())
+			return false
+		}
+		wantDesc := wantElements[i]
+		i++
+		if wantDesc.FullName() != gotDesc.FullName() {
+			t.Errorf("unexpected element: want %s ; got %s", wantDesc.FullName(), gotDesc.FullName())
+			return false
+		}
+		switch wantDesc := wantDesc.(type) {
+		case protoreflect.EnumDescriptor:
+			gotEnum, _ := gotDesc.(protoreflect.EnumDescriptor)
+			if wantDesc.IsClosed() != gotEnum.IsClosed() {
+				t.Errorf("%s: closed is %v, want %v", wantDesc.FullName(), gotEnum.IsClosed(), wantDesc.IsClosed())
+			}
+		case protoreflect.FieldDescriptor:
+			gotField, _ := gotDesc.(protoreflect.FieldDescriptor)
+			if wantDesc.Cardinality() != gotField.Cardinality() ||
+				wantDesc.Kind() != gotField.Kind() ||
+				wantDesc.HasPresence() != gotField.HasPresence() ||
+				wantDesc.IsPacked() != gotField.IsPacked() ||
+				wantDesc.HasDefault() != gotField.HasDefault() ||
+				wantDesc.Default().Interface() != gotField.Default().Interface() {
+				t.Errorf("%s: semantics changed", wantDesc.FullName())
+			}
+		}
+		return true
+	})
+	if i != len(wantElements) {
+		t.Errorf("expected %d elements, got %d", len(wantElements), i)
+	}
+}
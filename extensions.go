@@ -0,0 +1,179 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// newDerivedExtensionResolver returns an ExtensionResolver that agrees with
+// the descriptor resolver, for Reflectors configured with
+// WithDescriptorResolver but not WithExtensionResolver.
+//
+// If the descriptor resolver can range over its files (as *protoregistry.Files
+// can), we index the extensions in those files. Otherwise, there's no way to
+// discover the resolver's extensions, so we fall back to
+// protoregistry.GlobalTypes. Either way, an extension is only returned if the
+// descriptor resolver agrees that it exists, so extensions hidden by a
+// DescriptorResolverContext stay hidden.
+func newDerivedExtensionResolver(resolver protodesc.Resolver) ExtensionResolver {
+	if resolver == globalFiles || resolver == protoregistry.GlobalFiles {
+		return protoregistry.GlobalTypes
+	}
+	derived := &derivedExtensionResolver{resolver: resolver}
+	if files, ok := resolver.(FileRanger); ok {
+		derived.index = &extensionIndex{files: files, numFiles: -1}
+	}
+	return derived
+}
+
+type derivedExtensionResolver struct {
+	resolver protodesc.Resolver
+	index    *extensionIndex // nil if the resolver can't range over its files
+}
+
+func (r *derivedExtensionResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	ext, err := r.candidates().FindExtensionByName(field)
+	if err != nil {
+		return nil, err
+	}
+	return r.confirm(context.Background(), ext)
+}
+
+func (r *derivedExtensionResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return r.FindExtensionByNumberContext(context.Background(), message, field)
+}
+
+func (r *derivedExtensionResolver) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	r.RangeExtensionsByMessageContext(context.Background(), message, f)
+}
+
+func (r *derivedExtensionResolver) FindExtensionByNumberContext(
+	ctx context.Context,
+	message protoreflect.FullName,
+	field protoreflect.FieldNumber,
+) (protoreflect.ExtensionType, error) {
+	ext, err := r.candidates().FindExtensionByNumber(message, field)
+	if err != nil {
+		return nil, err
+	}
+	return r.confirm(ctx, ext)
+}
+
+func (r *derivedExtensionResolver) RangeExtensionsByMessageContext(
+	ctx context.Context,
+	message protoreflect.FullName,
+	f func(protoreflect.ExtensionType) bool,
+) {
+	r.candidates().RangeExtensionsByMessage(message, func(ext protoreflect.ExtensionType) bool {
+		confirmed, err := r.confirm(ctx, ext)
+		if err != nil {
+			return true
+		}
+		return f(confirmed)
+	})
+}
+
+// candidates returns the extensions that the descriptor resolver might know
+// about.
+func (r *derivedExtensionResolver) candidates() ExtensionResolver {
+	if r.index == nil {
+		return protoregistry.GlobalTypes
+	}
+	return r.index.types()
+}
+
+// confirm checks that the descriptor resolver knows about the extension,
+// returning a type for the resolver's descriptor.
+func (r *derivedExtensionResolver) confirm(ctx context.Context, ext protoreflect.ExtensionType) (protoreflect.ExtensionType, error) {
+	candidate := ext.TypeDescriptor()
+	desc, err := findDescriptorByName(ctx, r.resolver, candidate.FullName())
+	if err != nil {
+		return nil, err
+	}
+	if desc == candidate.Descriptor() {
+		return ext, nil
+	}
+	extDesc, ok := desc.(protoreflect.ExtensionDescriptor)
+	if !ok || !extDesc.IsExtension() ||
+		extDesc.Number() != candidate.Number() ||
+		extDesc.ContainingMessage().FullName() != candidate.ContainingMessage().FullName() {
+		return nil, fmt.Errorf("extension %s: %w", candidate.FullName(), protoregistry.NotFound)
+	}
+	return dynamicpb.NewExtensionType(extDesc), nil
+}
+
+// extensionIndex holds dynamic types for the extensions in a set of files. If
+// the files report how many there are (as *protoregistry.Files does), the
+// index is rebuilt whenever that number changes. Otherwise, it's built once,
+// on first use.
+type extensionIndex struct {
+	files FileRanger
+
+	mu       sync.Mutex
+	numFiles int
+	index    *protoregistry.Types
+}
+
+func (i *extensionIndex) types() *protoregistry.Types {
+	numFiles := 0
+	if counter, ok := i.files.(interface{ NumFiles() int }); ok {
+		numFiles = counter.NumFiles()
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.index == nil || numFiles != i.numFiles {
+		index := &protoregistry.Types{}
+		rangeExtensions(i.files, func(ext protoreflect.ExtensionDescriptor) bool {
+			// Extensions whose numbers conflict can't all be indexed, so the
+			// first one wins.
+			_ = index.RegisterExtension(dynamicpb.NewExtensionType(ext))
+			return true
+		})
+		i.index = index
+		i.numFiles = numFiles
+	}
+	return i.index
+}
+
+// rangeExtensions calls f for each extension declared in the files, including
+// extensions nested in messages, until f returns false.
+func rangeExtensions(files FileRanger, f func(protoreflect.ExtensionDescriptor) bool) {
+	ok := true
+	rangeDescriptors := func(extensions protoreflect.ExtensionDescriptors) {
+		for i := 0; ok && i < extensions.Len(); i++ {
+			ok = f(extensions.Get(i))
+		}
+	}
+	var rangeMessages func(protoreflect.MessageDescriptors)
+	rangeMessages = func(messages protoreflect.MessageDescriptors) {
+		for i := 0; ok && i < messages.Len(); i++ {
+			rangeDescriptors(messages.Get(i).Extensions())
+			rangeMessages(messages.Get(i).Messages())
+		}
+	}
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		rangeDescriptors(file.Extensions())
+		rangeMessages(file.Messages())
+		return ok
+	})
+}
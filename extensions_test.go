@@ -0,0 +1,165 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDerivedExtensionResolver(t *testing.T) {
+	t.Parallel()
+	checkNumbers := func(t *testing.T, stream *ClientStream, message protoreflect.FullName, expected []protoreflect.FieldNumber) {
+		t.Helper()
+		numbers, err := stream.AllExtensionNumbers(message)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, numbers) {
+			t.Fatalf("unexpected extension numbers: want %v ; got %v", expected, numbers)
+		}
+	}
+
+	t.Run("files", func(t *testing.T) {
+		t.Parallel()
+		files := &protoregistry.Files{}
+		registerFile(t, files, newExtendableFile("acme/v1/base.proto", nil))
+		reflector := NewReflector(&staticNames{}, WithDescriptorResolver(files))
+		stream := newTestStream(t, reflector)
+		checkNumbers(t, stream, "acme.v1.Base", []protoreflect.FieldNumber{100, 101})
+		descriptors, err := stream.FileContainingExtension("acme.v1.Base", 101)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(descriptors) != 1 || descriptors[0].GetName() != "acme/v1/base.proto" {
+			t.Fatalf("unexpected files: %v", descriptors)
+		}
+		// The reflecttest extensions are in GlobalTypes, but not in the
+		// Reflector's files.
+		_, err = stream.AllExtensionNumbers("connect.reflecttest.v1.Extendable")
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+		_, err = stream.FileContainingExtension("connect.reflecttest.v1.Extendable", 10)
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+
+		// Files registered later are indexed too.
+		registerFile(t, files, newExtendableFile("acme/v1/more.proto", []string{"acme/v1/base.proto"}))
+		checkNumbers(t, stream, "acme.v1.Base", []protoreflect.FieldNumber{100, 101, 102})
+	})
+	t.Run("opaque_resolver", func(t *testing.T) {
+		t.Parallel()
+		resolver := &hiddenNames{
+			Resolver: protoregistry.GlobalFiles,
+			hidden:   "connect.reflecttest.v1.localized_message",
+		}
+		stream := newTestStream(t, NewReflector(&staticNames{}, WithDescriptorResolver(resolver)))
+		checkNumbers(t, stream, "connect.reflecttest.v1.Extendable", []protoreflect.FieldNumber{10})
+		if _, err := stream.FileContainingExtension("connect.reflecttest.v1.Extendable", 10); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, err := stream.FileContainingExtension("connect.reflecttest.v1.Extendable", 11)
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+	})
+	t.Run("explicit_resolver", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewReflector(
+			&staticNames{},
+			WithDescriptorResolver(&protoregistry.Files{}),
+			WithExtensionResolver(protoregistry.GlobalTypes),
+		)
+		if reflector.extensionResolver != protoregistry.GlobalTypes {
+			t.Fatalf("expected explicit extension resolver to be kept, got %T", reflector.extensionResolver)
+		}
+	})
+}
+
+// newExtendableFile returns a file in package acme.v1. If the file has no
+// imports, it declares a message named Base and two extensions of it, one of
+// which is nested in a message. Otherwise, it declares one more extension of
+// Base.
+func newExtendableFile(path string, imports []string) *descriptorpb.FileDescriptorProto {
+	file := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String(path),
+		Package:    proto.String("acme.v1"),
+		Syntax:     proto.String("proto2"),
+		Dependency: imports,
+	}
+	newExtension := func(name string, number int32) *descriptorpb.FieldDescriptorProto {
+		return &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+			Extendee: proto.String(".acme.v1.Base"),
+		}
+	}
+	if len(imports) > 0 {
+		file.Extension = []*descriptorpb.FieldDescriptorProto{newExtension("more", 102)}
+		return file
+	}
+	file.MessageType = []*descriptorpb.DescriptorProto{
+		{
+			Name: proto.String("Base"),
+			ExtensionRange: []*descriptorpb.DescriptorProto_ExtensionRange{
+				{Start: proto.Int32(100), End: proto.Int32(200)},
+			},
+		},
+		{
+			Name:      proto.String("Holder"),
+			Extension: []*descriptorpb.FieldDescriptorProto{newExtension("nested", 101)},
+		},
+	}
+	file.Extension = []*descriptorpb.FieldDescriptorProto{newExtension("top_level", 100)}
+	return file
+}
+
+func registerFile(t *testing.T, files *protoregistry.Files, fileProto *descriptorpb.FileDescriptorProto) {
+	t.Helper()
+	file, err := protodesc.NewFile(fileProto, files)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if err := files.RegisterFile(file); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+// hiddenNames is a descriptor resolver that can't range over its files and
+// pretends that one descriptor doesn't exist.
+type hiddenNames struct {
+	protodesc.Resolver
+
+	hidden protoreflect.FullName
+}
+
+func (h *hiddenNames) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	if name == h.hidden {
+		return nil, fmt.Errorf("%s: %w", name, protoregistry.NotFound)
+	}
+	return h.Resolver.FindDescriptorByName(name)
+}
@@ -0,0 +1,170 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"sort"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// SchemaFingerprintHeader is the response header in which Reflectors created
+// with WithSchemaFingerprint send the fingerprint of their schema.
+const SchemaFingerprintHeader = "Reflection-Schema-Fingerprint"
+
+// WithSchemaFingerprint makes Reflectors send a fingerprint of their schema in
+// the SchemaFingerprintHeader response header of every reflection stream and
+// batch RPC. Clients can read it with ClientStream.SchemaFingerprint and use
+// it to key caches of downloaded descriptors: replicas serving the same schema
+// send the same fingerprint, and the fingerprint changes when the schema does.
+// See Reflector.SchemaFingerprint for what it covers.
+//
+// Computing the fingerprint requires serializing the whole schema, so it's
+// only done when this option is used. The digest of each file is cached along
+// with its serialized form (see WithDescriptorCacheLimit).
+func WithSchemaFingerprint() Option {
+	return &schemaFingerprintOption{}
+}
+
+// SchemaFingerprint returns a fingerprint of the schema the Reflector
+// describes: the names of the services listed by its Namer, and the files
+// that define them along with all their imports, as they're sent to clients.
+// The fingerprint is a hex-encoded SHA-256 digest of the sorted names and the
+// deterministically serialized files, sorted by path, so it doesn't depend on
+// the order in which they're listed or resolved. The names of services that
+// can't be found are included, but missing files are left out. The context is
+// passed to context-aware Namers and resolvers.
+//
+// The fingerprint describes the Reflector's own Namer and descriptor
+// resolver. If the Reflector uses a HostRouter, requests for other hosts may
+// be answered with different schemas.
+func (r *Reflector) SchemaFingerprint(ctx context.Context) (string, error) {
+	names, err := namesContext(ctx, r.namer)
+	if err != nil {
+		return "", fmt.Errorf("list services: %w", err)
+	}
+	names = append([]string(nil), names...)
+	sort.Strings(names)
+	files := make(map[string]protoreflect.FileDescriptor)
+	var queue []protoreflect.FileDescriptor
+	for _, name := range names {
+		desc, err := findDescriptorByName(ctx, r.descriptorResolver, protoreflect.FullName(name))
+		if err != nil {
+			continue
+		}
+		queue = append(queue, desc.ParentFile())
+	}
+	for len(queue) > 0 {
+		file := queue[0]
+		queue = queue[1:]
+		if _, ok := files[file.Path()]; ok || file.IsPlaceholder() {
+			continue
+		}
+		files[file.Path()] = file
+		imports := file.Imports()
+		for i := range imports.Len() {
+			queue = append(queue, imports.Get(i).FileDescriptor)
+		}
+	}
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	digest := sha256.New()
+	writeCount(digest, len(names))
+	for _, name := range names {
+		writeBytes(digest, []byte(name))
+	}
+	writeCount(digest, len(paths))
+	for _, path := range paths {
+		fileDigest, err := r.fileDigest(files[path])
+		if err != nil {
+			return "", fmt.Errorf("serialize %q: %w", path, err)
+		}
+		writeBytes(digest, []byte(path))
+		writeBytes(digest, fileDigest)
+	}
+	return hex.EncodeToString(digest.Sum(nil)), nil
+}
+
+// SchemaFingerprint returns the schema fingerprint sent by the server, if it
+// uses [WithSchemaFingerprint]. Otherwise, it returns an empty string.
+//
+// The fingerprint is read from the response headers, so like
+// [ClientStream.ResponseHeader], it blocks until the server sends them. It's
+// safest to call it after the first operation on the stream has completed.
+func (cs *ClientStream) SchemaFingerprint() string {
+	return cs.ResponseHeader().Get(SchemaFingerprintHeader)
+}
+
+// setSchemaFingerprint sets the fingerprint header. If the fingerprint can't
+// be computed, the header is left out: reflection requests may still succeed,
+// and errors are reported in response to them.
+func (r *Reflector) setSchemaFingerprint(ctx context.Context, header http.Header) {
+	fingerprint, err := r.SchemaFingerprint(ctx)
+	if err != nil {
+		return
+	}
+	header.Set(SchemaFingerprintHeader, fingerprint)
+}
+
+// fileDigest returns the SHA-256 digest of the deterministically serialized
+// file.
+func (r *Reflector) fileDigest(file protoreflect.FileDescriptor) ([]byte, error) {
+	if r.digests != nil {
+		if digest, ok := r.digests.get(file); ok {
+			return digest, nil
+		}
+	}
+	// Problems downleveling the file are reported when it's sent, not every
+	// time it's fingerprinted.
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(r.newFileProto(file, nil))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	digest := sum[:]
+	if r.digests != nil {
+		r.digests.put(file, digest)
+	}
+	return digest, nil
+}
+
+// writeCount and writeBytes write length-prefixed values, so that different
+// lists of values can't produce the same input to the hash.
+func writeCount(digest hash.Hash, count int) {
+	_, _ = digest.Write(binary.AppendUvarint(nil, uint64(count)))
+}
+
+func writeBytes(digest hash.Hash, data []byte) {
+	writeCount(digest, len(data))
+	_, _ = digest.Write(data)
+}
+
+type schemaFingerprintOption struct{}
+
+func (o *schemaFingerprintOption) apply(reflector *Reflector) {
+	reflector.fingerprintSchema = true
+}
@@ -0,0 +1,126 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestSchemaFingerprint(t *testing.T) {
+	t.Parallel()
+	const testServiceName = "connect.reflecttest.v1.TestService"
+	fingerprint := func(t *testing.T, reflector *Reflector) string {
+		t.Helper()
+		fingerprint, err := reflector.SchemaFingerprint(t.Context())
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return fingerprint
+	}
+	expected := fingerprint(t, NewStaticReflector(actualServiceName, testServiceName))
+	if len(expected) != 64 {
+		t.Fatalf("expected a hex-encoded SHA-256 digest, got %q", expected)
+	}
+
+	t.Run("deterministic", func(t *testing.T) {
+		t.Parallel()
+		reflectors := map[string]*Reflector{
+			"reordered": NewStaticReflector(testServiceName, actualServiceName),
+			"uncached": NewReflector(
+				&staticNames{names: []string{actualServiceName, testServiceName}},
+				WithDescriptorCacheLimit(0),
+			),
+		}
+		for name, reflector := range reflectors {
+			if actual := fingerprint(t, reflector); actual != expected {
+				t.Errorf("%s: unexpected fingerprint: want %s ; got %s", name, expected, actual)
+			}
+		}
+		// Cached digests don't change the fingerprint.
+		reflector := NewReflector(&staticNames{names: []string{testServiceName, actualServiceName}}, WithSchemaFingerprint())
+		for range 2 {
+			if actual := fingerprint(t, reflector); actual != expected {
+				t.Fatalf("unexpected fingerprint: want %s ; got %s", expected, actual)
+			}
+		}
+	})
+	t.Run("schema_changes", func(t *testing.T) {
+		t.Parallel()
+		if fingerprint(t, NewStaticReflector(actualServiceName)) == expected {
+			t.Error("expected removing a service to change the fingerprint")
+		}
+		// Services that can't be found are still listed.
+		if fingerprint(t, NewStaticReflector(actualServiceName, testServiceName, "acme.v1.Gone")) == expected {
+			t.Error("expected adding a service to change the fingerprint")
+		}
+		scrubbed := NewReflector(
+			&staticNames{names: []string{actualServiceName, testServiceName}},
+			&transformOption{transform: func(fileProto *descriptorpb.FileDescriptorProto) {
+				fileProto.Options = nil
+			}},
+		)
+		if fingerprint(t, scrubbed) == expected {
+			t.Error("expected the fingerprint to cover the files as they're sent")
+		}
+	})
+	t.Run("stream", func(t *testing.T) {
+		t.Parallel()
+		for _, withFingerprint := range []bool{true, false} {
+			options := []Option{}
+			if withFingerprint {
+				options = append(options, WithSchemaFingerprint())
+			}
+			reflector := NewReflector(&staticNames{names: []string{actualServiceName, testServiceName}}, options...)
+			stream := newTestStream(t, reflector)
+			if _, err := stream.ListServices(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			want := ""
+			if withFingerprint {
+				want = expected
+			}
+			if actual := stream.SchemaFingerprint(); actual != want {
+				t.Errorf("unexpected fingerprint: want %q ; got %q", want, actual)
+			}
+			if _, err := stream.Close(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		}
+	})
+	t.Run("batch", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewReflector(&staticNames{names: []string{actualServiceName, testServiceName}}, WithSchemaFingerprint())
+		mux := http.NewServeMux()
+		mux.Handle(NewHandlerV1(reflector))
+		mux.Handle(NewBatchHandler(reflector))
+		// Only HTTP/1.1 is supported, so the client falls back to batch RPCs.
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		stream := NewClient(server.Client(), server.URL).NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if actual := stream.SchemaFingerprint(); actual != expected {
+			t.Errorf("unexpected fingerprint: want %q ; got %q", expected, actual)
+		}
+	})
+}
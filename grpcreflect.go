@@ -33,6 +33,10 @@ import (
 	"io"
 	"net/http"
 	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"connectrpc.com/connect"
 	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
@@ -67,7 +71,10 @@ var (
 //
 // Note that because the reflection API requires bidirectional streaming, the
 // returned handler doesn't support HTTP/1.1. If your server must also support
-// older tools that use the v1alpha server reflection API, see NewHandlerV1Alpha.
+// older tools that use the v1alpha server reflection API, see NewHandlerV1Alpha,
+// or NewHandler, which serves both versions.
+// To support clients that can only use HTTP/1.1, also mount the handler from
+// NewBatchHandler.
 func NewHandlerV1(reflector *Reflector, options ...connect.HandlerOption) (string, http.Handler) {
 	return newHandler(reflector, serviceURLPathV1, options)
 }
@@ -82,13 +89,43 @@ func NewHandlerV1Alpha(reflector *Reflector, options ...connect.HandlerOption) (
 	return newHandler(reflector, serviceURLPathV1Alpha, options)
 }
 
+// NewHandler constructs a single HTTP handler that implements both v1 and
+// v1alpha of the gRPC server reflection API under a path prefix like
+// "/internal". It returns the handler and the path on which to mount it:
+//
+//	mux.Handle(grpcreflect.NewHandler("/internal", reflector))
+//
+// Clients created with NewClient must include the prefix in their base URL
+// (for example, "https://example.com/internal"). Keep in mind that most gRPC
+// clients, including grpcurl, can't use prefixed paths.
+//
+// The prefix must not be empty (or "/"), since the handler would then have to
+// claim every path on the Mux. NewHandler panics if it is. To serve
+// reflection without a prefix, mount the handlers from NewHandlerV1 and
+// NewHandlerV1Alpha instead.
+//
+// Like NewHandlerV1, the returned handler doesn't support HTTP/1.1.
+func NewHandler(prefix string, reflector *Reflector, options ...connect.HandlerOption) (string, http.Handler) {
+	prefix = strings.Trim(prefix, "/")
+	if prefix == "" {
+		panic("grpcreflect: NewHandler requires a path prefix; use NewHandlerV1 and NewHandlerV1Alpha without one")
+	}
+	mux := http.NewServeMux()
+	mux.Handle(newHandler(reflector, serviceURLPathV1, options))
+	mux.Handle(newHandler(reflector, serviceURLPathV1Alpha, options))
+	prefix = "/" + prefix
+	return prefix + "/", http.StripPrefix(prefix, mux)
+}
+
 // Reflector implements the underlying logic for gRPC's protobuf server
 // reflection. They're configurable, so they can support straightforward
 // process-local reflection or more complex proxying.
 //
 // Keep in mind that by default, Reflectors expose every protobuf type and
 // extension compiled into your binary. Think twice before including the
-// default Reflector in a public API.
+// default Reflector in a public API, and consider using WithServiceFilesOnly.
+// Public APIs should also limit the resources each client may use, with
+// options like WithMaxConcurrentStreams and WithIdleTimeout.
 //
 // For more information, see
 // https://github.com/grpc/grpc-go/blob/master/Documentation/server-reflection-tutorial.md,
@@ -98,6 +135,30 @@ type Reflector struct {
 	namer              Namer
 	extensionResolver  ExtensionResolver
 	descriptorResolver protodesc.Resolver
+	hostRouter         HostRouter
+	restrictFiles      bool
+	extraAllowedFiles  []string
+	transforms         []func(*descriptorpb.FileDescriptorProto)
+	cache              *descriptorCache
+	observer           Observer
+	tolerantStreams    bool
+	batchCacheControl  string
+	dependencies       FileDependencies
+	noDeduplication    bool
+	strictDependencies bool
+	downlevelEditions  bool
+	reportDownlevel    func(*DownlevelError)
+	sourceInfo         map[string]*sourceInfoFile
+	fingerprintSchema  bool
+	digests            *descriptorCache
+	aggregate          *aggregateResolver
+
+	maxRequestsPerStream int
+	maxBytesPerStream    int
+	maxResponseSize      int
+	idleTimeout          time.Duration
+	maxStreams           int
+	activeStreams        atomic.Int64
 }
 
 // NewReflector constructs a highly configurable Reflector: it can serve a
@@ -110,12 +171,20 @@ type Reflector struct {
 func NewReflector(namer Namer, options ...Option) *Reflector {
 	reflector := &Reflector{
 		namer:              namer,
-		extensionResolver:  protoregistry.GlobalTypes,
 		descriptorResolver: globalFiles,
+		cache:              newDescriptorCache(0),
+		batchCacheControl:  defaultBatchCacheControl,
+		dependencies:       FileDependenciesTransitive,
 	}
 	for _, option := range options {
 		option.apply(reflector)
 	}
+	if reflector.extensionResolver == nil {
+		reflector.extensionResolver = newDerivedExtensionResolver(reflector.descriptorResolver)
+	}
+	if reflector.fingerprintSchema && reflector.cache != nil {
+		reflector.digests = newDescriptorCache(reflector.cache.limit)
+	}
 	return reflector
 }
 
@@ -133,118 +202,270 @@ func NewStaticReflector(services ...string) *Reflector {
 
 // serverReflectionInfo implements the gRPC server reflection API.
 func (r *Reflector) serverReflectionInfo(
-	_ context.Context,
+	ctx context.Context,
 	stream *connect.BidiStream[
 		reflectionv1.ServerReflectionRequest,
 		reflectionv1.ServerReflectionResponse,
 	],
 ) error {
-	fileDescriptorsSent := &fileDescriptorNameSet{}
+	if err := r.acquireStream(); err != nil {
+		return err
+	}
+	defer r.releaseStream()
+	receive := stream.Receive
+	if r.idleTimeout > 0 {
+		receiver := newIdleReceiver(stream.Receive, r.idleTimeout)
+		defer receiver.Close()
+		receive = receiver.Receive
+	}
+	ctx = newStreamInfoContext(ctx, StreamInfo{
+		Spec:          stream.Spec(),
+		Peer:          stream.Peer(),
+		RequestHeader: stream.RequestHeader(),
+	})
+	session := newReflectionSession()
+	ctx = newSessionContext(ctx, session)
+	if r.fingerprintSchema {
+		r.setSchemaFingerprint(ctx, stream.ResponseHeader())
+	}
 	for {
-		request, err := stream.Receive()
+		request, err := receive()
 		if errors.Is(err, io.EOF) {
 			return nil
 		} else if err != nil {
 			return err
 		}
-		// The server reflection API sends file descriptors as uncompressed
-		// Protobuf-serialized bytes.
-		response := &reflectionv1.ServerReflectionResponse{
-			ValidHost:       request.Host,
+		response, err := r.answer(ctx, request, session)
+		if err != nil {
+			return err
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		}
+	}
+}
+
+// reflectionSession holds the state of a reflection stream (or a batch of
+// requests, which is treated like a stream).
+type reflectionSession struct {
+	// Reflectors for different hosts may have different schemas, so we track
+	// the state of each one separately.
+	states map[*Reflector]*streamState
+	usage  streamUsage
+
+	// Sources whose schema changes over time pin a snapshot of it to the
+	// session, since the states above assume that the schema is stable.
+	pinnedMu sync.Mutex
+	pinned   map[any]any
+}
+
+func newReflectionSession() *reflectionSession {
+	return &reflectionSession{states: make(map[*Reflector]*streamState, 1)}
+}
+
+// answer routes a request to the right Reflector and answers it. It only
+// returns an error if the session should be terminated.
+func (r *Reflector) answer(
+	ctx context.Context,
+	request *reflectionv1.ServerReflectionRequest,
+	session *reflectionSession,
+) (*reflectionv1.ServerReflectionResponse, error) {
+	start := time.Now()
+	event := &RequestEvent{Host: request.Host}
+	if err := r.addRequest(&session.usage); err != nil {
+		event.Type, event.Argument, event.ExtensionNumber = describeRequest(request)
+		r.observeFailure(ctx, event, start, err)
+		return nil, err
+	}
+	var response *reflectionv1.ServerReflectionResponse
+	if reflector, validHost, err := r.route(request.Host); err != nil {
+		event.Type, event.Argument, event.ExtensionNumber = describeRequest(request)
+		event.Err = err
+		response = &reflectionv1.ServerReflectionResponse{
 			OriginalRequest: request,
+			MessageResponse: newErrorResponse(connect.CodeNotFound, err),
 		}
-		switch messageRequest := request.MessageRequest.(type) {
-		case *reflectionv1.ServerReflectionRequest_FileByFilename:
-			data, err := r.getFileByFilename(messageRequest.FileByFilename, fileDescriptorsSent)
-			if err != nil {
-				response.MessageResponse = newNotFoundResponse(err)
-			} else {
-				response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
-					FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
-				}
-			}
-		case *reflectionv1.ServerReflectionRequest_FileContainingSymbol:
-			data, err := r.getFileContainingSymbol(
-				messageRequest.FileContainingSymbol,
-				fileDescriptorsSent,
-			)
-			if err != nil {
-				response.MessageResponse = newNotFoundResponse(err)
-			} else {
-				response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
-					FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
-				}
+	} else {
+		state := session.states[reflector]
+		if state == nil {
+			state = &streamState{
+				maxResponseSize: r.maxResponseSize,
+				dependencies:    r.dependencies,
+				deduplicate:     !r.noDeduplication,
+				strict:          r.strictDependencies,
 			}
-		case *reflectionv1.ServerReflectionRequest_FileContainingExtension:
-			msgFQN := messageRequest.FileContainingExtension.ContainingType
-			extNumber := messageRequest.FileContainingExtension.ExtensionNumber
-			data, err := r.getFileContainingExtension(msgFQN, extNumber, fileDescriptorsSent)
-			if err != nil {
-				response.MessageResponse = newNotFoundResponse(err)
-			} else {
-				response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
-					FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
-				}
+			session.states[reflector] = state
+		}
+		response, err = reflector.handleRequest(ctx, request, state, event)
+		if err != nil {
+			r.observeFailure(ctx, event, start, err)
+			return nil, err
+		}
+		response.ValidHost = validHost
+	}
+	if err := r.addBytes(&session.usage, event.Bytes); err != nil {
+		r.observeFailure(ctx, event, start, err)
+		return nil, err
+	}
+	r.observeRequest(ctx, event, start, response)
+	return response, nil
+}
+
+// handleRequest answers a single reflection request, recording what it did in
+// the event. It only returns an error if the request is so malformed that the
+// stream should be terminated (see WithTolerantStreams).
+func (r *Reflector) handleRequest(
+	ctx context.Context,
+	request *reflectionv1.ServerReflectionRequest,
+	state *streamState,
+	event *RequestEvent,
+) (*reflectionv1.ServerReflectionResponse, error) {
+	// The server reflection API sends file descriptors as uncompressed
+	// Protobuf-serialized bytes.
+	response := &reflectionv1.ServerReflectionResponse{
+		OriginalRequest: request,
+	}
+	event.Type, event.Argument, event.ExtensionNumber = describeRequest(request)
+	if err := validateRequest(request); err != nil {
+		event.Err = err
+		response.MessageResponse = newResolverErrorResponse(err)
+		return response, nil
+	}
+	switch messageRequest := request.MessageRequest.(type) {
+	case *reflectionv1.ServerReflectionRequest_FileByFilename:
+		data, err := r.getFileByFilename(ctx, messageRequest.FileByFilename, state)
+		if err != nil {
+			event.Err = err
+			response.MessageResponse = newResolverErrorResponse(err)
+		} else {
+			event.setFiles(data)
+			response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
 			}
-		case *reflectionv1.ServerReflectionRequest_AllExtensionNumbersOfType:
-			nums, err := r.getAllExtensionNumbersOfType(messageRequest.AllExtensionNumbersOfType)
-			if err != nil {
-				response.MessageResponse = newNotFoundResponse(err)
-			} else {
-				response.MessageResponse = &reflectionv1.ServerReflectionResponse_AllExtensionNumbersResponse{
-					AllExtensionNumbersResponse: &reflectionv1.ExtensionNumberResponse{
-						BaseTypeName:    messageRequest.AllExtensionNumbersOfType,
-						ExtensionNumber: nums,
-					},
-				}
+		}
+	case *reflectionv1.ServerReflectionRequest_FileContainingSymbol:
+		data, err := r.getFileContainingSymbol(
+			ctx,
+			messageRequest.FileContainingSymbol,
+			state,
+		)
+		if err != nil {
+			event.Err = err
+			response.MessageResponse = newResolverErrorResponse(err)
+		} else {
+			event.setFiles(data)
+			response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
 			}
-		case *reflectionv1.ServerReflectionRequest_ListServices:
-			services := r.namer.Names()
-			serviceResponses := make([]*reflectionv1.ServiceResponse, len(services))
-			for i, name := range services {
-				serviceResponses[i] = &reflectionv1.ServiceResponse{Name: name}
+		}
+	case *reflectionv1.ServerReflectionRequest_FileContainingExtension:
+		msgFQN := messageRequest.FileContainingExtension.ContainingType
+		extNumber := messageRequest.FileContainingExtension.ExtensionNumber
+		data, err := r.getFileContainingExtension(ctx, msgFQN, extNumber, state)
+		if err != nil {
+			event.Err = err
+			response.MessageResponse = newResolverErrorResponse(err)
+		} else {
+			event.setFiles(data)
+			response.MessageResponse = &reflectionv1.ServerReflectionResponse_FileDescriptorResponse{
+				FileDescriptorResponse: &reflectionv1.FileDescriptorResponse{FileDescriptorProto: data},
 			}
-			response.MessageResponse = &reflectionv1.ServerReflectionResponse_ListServicesResponse{
-				ListServicesResponse: &reflectionv1.ListServiceResponse{Service: serviceResponses},
+		}
+	case *reflectionv1.ServerReflectionRequest_AllExtensionNumbersOfType:
+		nums, err := r.getAllExtensionNumbersOfType(ctx, messageRequest.AllExtensionNumbersOfType, state)
+		if err != nil {
+			event.Err = err
+			response.MessageResponse = newResolverErrorResponse(err)
+		} else {
+			response.MessageResponse = &reflectionv1.ServerReflectionResponse_AllExtensionNumbersResponse{
+				AllExtensionNumbersResponse: &reflectionv1.ExtensionNumberResponse{
+					BaseTypeName:    messageRequest.AllExtensionNumbersOfType,
+					ExtensionNumber: nums,
+				},
 			}
-		default:
-			return connect.NewError(connect.CodeInvalidArgument, fmt.Errorf(
-				"invalid MessageRequest: %v",
-				request.MessageRequest,
-			))
 		}
-		if err := stream.Send(response); err != nil {
-			return err
+	case *reflectionv1.ServerReflectionRequest_ListServices:
+		services, err := namesContext(ctx, r.namer)
+		if err != nil {
+			event.Err = err
+			response.MessageResponse = newResolverErrorResponse(err)
+			break
+		}
+		serviceResponses := make([]*reflectionv1.ServiceResponse, len(services))
+		for i, name := range services {
+			serviceResponses[i] = &reflectionv1.ServiceResponse{Name: name}
+		}
+		response.MessageResponse = &reflectionv1.ServerReflectionResponse_ListServicesResponse{
+			ListServicesResponse: &reflectionv1.ListServiceResponse{Service: serviceResponses},
 		}
+	default:
+		err := connect.NewError(connect.CodeInvalidArgument, fmt.Errorf(
+			"invalid MessageRequest: %v",
+			request.MessageRequest,
+		))
+		if !r.tolerantStreams {
+			return nil, err
+		}
+		event.Err = err
+		response.MessageResponse = newResolverErrorResponse(err)
+	}
+	return response, nil
+}
+
+// describeRequest returns the type and arguments of a reflection request, as
+// reported to Observers.
+func describeRequest(request *reflectionv1.ServerReflectionRequest) (RequestType, string, int32) {
+	switch messageRequest := request.MessageRequest.(type) {
+	case *reflectionv1.ServerReflectionRequest_FileByFilename:
+		return RequestTypeFileByFilename, messageRequest.FileByFilename, 0
+	case *reflectionv1.ServerReflectionRequest_FileContainingSymbol:
+		return RequestTypeFileContainingSymbol, messageRequest.FileContainingSymbol, 0
+	case *reflectionv1.ServerReflectionRequest_FileContainingExtension:
+		ext := messageRequest.FileContainingExtension
+		return RequestTypeFileContainingExtension, ext.GetContainingType(), ext.GetExtensionNumber()
+	case *reflectionv1.ServerReflectionRequest_AllExtensionNumbersOfType:
+		return RequestTypeAllExtensionNumbersOfType, messageRequest.AllExtensionNumbersOfType, 0
+	case *reflectionv1.ServerReflectionRequest_ListServices:
+		return RequestTypeListServices, "", 0
 	}
+	return 0, "", 0
 }
 
-func (r *Reflector) getFileByFilename(fname string, sent *fileDescriptorNameSet) ([][]byte, error) {
-	fd, err := r.descriptorResolver.FindFileByPath(fname)
+func (r *Reflector) getFileByFilename(ctx context.Context, fname string, state *streamState) ([][]byte, error) {
+	fd, err := findFileByPath(ctx, r.descriptorResolver, fname)
 	if err != nil {
 		return nil, err
 	}
-	return fileDescriptorWithDependencies(fd, sent)
+	if err := r.checkFileAllowed(ctx, fd, state); err != nil {
+		return nil, err
+	}
+	return r.fileWithDependencies(fd, state)
 }
 
-func (r *Reflector) getFileContainingSymbol(fqn string, sent *fileDescriptorNameSet) ([][]byte, error) {
-	desc, err := r.descriptorResolver.FindDescriptorByName(protoreflect.FullName(fqn))
+func (r *Reflector) getFileContainingSymbol(ctx context.Context, fqn string, state *streamState) ([][]byte, error) {
+	desc, err := findDescriptorByName(ctx, r.descriptorResolver, protoreflect.FullName(fqn))
 	if err != nil {
 		return nil, err
 	}
 	fd := desc.ParentFile()
 	if fd == nil {
-		return nil, fmt.Errorf("no file for symbol %s", fqn)
+		return nil, fmt.Errorf("no file for symbol %s: %w", fqn, protoregistry.NotFound)
 	}
-	return fileDescriptorWithDependencies(fd, sent)
+	if err := r.checkFileAllowed(ctx, fd, state); err != nil {
+		return nil, err
+	}
+	return r.fileWithDependencies(fd, state)
 }
 
 func (r *Reflector) getFileContainingExtension(
+	ctx context.Context,
 	msgFQN string,
 	extNumber int32,
-	sent *fileDescriptorNameSet,
+	state *streamState,
 ) ([][]byte, error) {
-	extension, err := r.extensionResolver.FindExtensionByNumber(
+	extension, err := findExtensionByNumber(
+		ctx,
+		r.extensionResolver,
 		protoreflect.FullName(msgFQN),
 		protoreflect.FieldNumber(extNumber),
 	)
@@ -253,21 +474,46 @@ func (r *Reflector) getFileContainingExtension(
 	}
 	fd := extension.TypeDescriptor().ParentFile()
 	if fd == nil {
-		return nil, fmt.Errorf("no file for extension %d of message %s", extNumber, msgFQN)
+		return nil, fmt.Errorf("no file for extension %d of message %s: %w", extNumber, msgFQN, protoregistry.NotFound)
+	}
+	if err := r.checkFileAllowed(ctx, fd, state); err != nil {
+		return nil, err
 	}
-	return fileDescriptorWithDependencies(fd, sent)
+	return r.fileWithDependencies(fd, state)
 }
 
-func (r *Reflector) getAllExtensionNumbersOfType(fqn string) ([]int32, error) {
+func (r *Reflector) getAllExtensionNumbersOfType(ctx context.Context, fqn string, state *streamState) ([]int32, error) {
 	nums := []int32{}
 	name := protoreflect.FullName(fqn)
-	r.extensionResolver.RangeExtensionsByMessage(name, func(ext protoreflect.ExtensionType) bool {
+	if r.restrictFiles {
+		// Don't reveal anything about messages the caller can't see.
+		desc, err := findDescriptorByName(ctx, r.descriptorResolver, name)
+		if err != nil {
+			return nil, err
+		}
+		if err := r.checkFileAllowed(ctx, desc.ParentFile(), state); err != nil {
+			return nil, err
+		}
+	}
+	var allowedErr error
+	rangeExtensionsByMessage(ctx, r.extensionResolver, name, func(ext protoreflect.ExtensionType) bool {
+		if r.restrictFiles {
+			if err := r.checkFileAllowed(ctx, ext.TypeDescriptor().ParentFile(), state); errors.Is(err, protoregistry.NotFound) {
+				return true
+			} else if err != nil {
+				allowedErr = err
+				return false
+			}
+		}
 		num := int32(ext.TypeDescriptor().Number())
 		nums = append(nums, num)
 		return true
 	})
+	if allowedErr != nil {
+		return nil, allowedErr
+	}
 	if len(nums) == 0 {
-		if _, err := r.descriptorResolver.FindDescriptorByName(name); err != nil {
+		if _, err := findDescriptorByName(ctx, r.descriptorResolver, name); err != nil {
 			return nil, err
 		}
 	}
@@ -280,6 +526,8 @@ func (r *Reflector) getAllExtensionNumbersOfType(fqn string) ([]int32, error) {
 // A Namer lists the fully-qualified Protobuf service names available for
 // reflection (for example, "acme.user.v1.UserService"). Namers must be safe to
 // call concurrently.
+//
+// Namers that need to know who's asking may also implement NamerContext.
 type Namer interface {
 	Names() []string
 }
@@ -303,7 +551,8 @@ type Option interface {
 }
 
 // WithExtensionResolver sets the resolver used to find Protobuf extensions. By
-// default, Reflectors use protoregistry.GlobalTypes.
+// default, Reflectors use protoregistry.GlobalTypes, or derive extensions from
+// the resolver passed to WithDescriptorResolver.
 func WithExtensionResolver(resolver ExtensionResolver) Option {
 	return &extensionResolverOption{resolver: resolver}
 }
@@ -311,6 +560,17 @@ func WithExtensionResolver(resolver ExtensionResolver) Option {
 // WithDescriptorResolver sets the resolver used to find Protobuf type
 // information (typically called a "descriptor"). By default, Reflectors use
 // protoregistry.GlobalFiles.
+//
+// Unless WithExtensionResolver is also used, the Reflector derives its
+// extensions from the descriptor resolver, so that answers about extensions
+// agree with answers about files. If the resolver can range over its files (as
+// *protoregistry.Files can; see FileRanger), the Reflector indexes the
+// extensions they declare, updating the index when the number of files
+// changes. Otherwise, the Reflector uses the extensions in
+// protoregistry.GlobalTypes that the resolver also knows about.
+//
+// Resolvers that need to know who's asking may also implement
+// DescriptorResolverContext.
 func WithDescriptorResolver(resolver protodesc.Resolver) Option {
 	return &descriptorResolverOption{resolver: resolver}
 }
@@ -319,13 +579,40 @@ func WithDescriptorResolver(resolver protodesc.Resolver) Option {
 // about the registered Protobuf extensions. protoregistry.GlobalTypes
 // implements ExtensionResolver.
 //
-// ExtensionResolvers must be safe to call concurrently.
+// ExtensionResolvers must be safe to call concurrently. Those that need to know
+// who's asking may also implement ExtensionResolverContext.
 type ExtensionResolver interface {
 	protoregistry.ExtensionTypeResolver
 
 	RangeExtensionsByMessage(protoreflect.FullName, func(protoreflect.ExtensionType) bool)
 }
 
+// streamState holds what a Reflector remembers about a single reflection stream.
+type streamState struct {
+	sent fileDescriptorNameSet
+	// maxResponseSize is the limit on the size of the file descriptors in a
+	// single response, or zero if there's no limit.
+	maxResponseSize int
+	// dependencies and deduplicate control which files are sent in response
+	// to each request.
+	dependencies FileDependencies
+	deduplicate  bool
+	// strict is true if files with missing imports shouldn't be sent.
+	strict bool
+	// allowedFiles is only used when the Reflector restricts the files it
+	// serves. It's computed on first use.
+	allowedFiles map[string]struct{}
+}
+
+// sentFiles returns the set of files already sent on the stream, or nil if
+// responses aren't deduplicated.
+func (s *streamState) sentFiles() *fileDescriptorNameSet {
+	if !s.deduplicate {
+		return nil
+	}
+	return &s.sent
+}
+
 type fileDescriptorNameSet struct {
 	names map[string]struct{}
 }
@@ -337,21 +624,103 @@ func (s *fileDescriptorNameSet) Insert(fd protoreflect.FileDescriptor) {
 	s.names[fd.Path()] = struct{}{}
 }
 
+func (s *fileDescriptorNameSet) Remove(fd protoreflect.FileDescriptor) {
+	delete(s.names, fd.Path())
+}
+
 func (s *fileDescriptorNameSet) Contains(fd protoreflect.FileDescriptor) bool {
 	_, ok := s.names[fd.Path()]
 	return ok
 }
 
-func fileDescriptorWithDependencies(rootFile protoreflect.FileDescriptor, sent *fileDescriptorNameSet) ([][]byte, error) {
+// encodeFile serializes a file descriptor, applying any transformations
+// configured on the Reflector.
+func (r *Reflector) encodeFile(file protoreflect.FileDescriptor) ([]byte, error) {
+	if r.cache != nil {
+		if data, ok := r.cache.get(file); ok {
+			return data, nil
+		}
+	}
+	data, err := proto.Marshal(r.newFileProto(file, r.reportDownlevel))
+	if err != nil {
+		return nil, err
+	}
+	if r.cache != nil {
+		r.cache.put(file, data)
+	}
+	return data, nil
+}
+
+// newFileProto converts the file to the form sent to clients. If the file
+// can't be downleveled, the error is passed to report, if it's non-nil.
+func (r *Reflector) newFileProto(file protoreflect.FileDescriptor, report func(*DownlevelError)) *descriptorpb.FileDescriptorProto {
+	var fileProto *descriptorpb.FileDescriptorProto
+	if source := r.aggregate.owner(file); source != nil {
+		// Aggregates convert files as their source would, and then apply
+		// their own options.
+		fileProto = source.newFileProto(file, report)
+	} else {
+		fileProto = protodesc.ToFileDescriptorProto(file)
+	}
+	if r.sourceInfo != nil {
+		r.attachSourceInfo(fileProto)
+	}
+	if r.downlevelEditions && fileProto.GetSyntax() == "editions" {
+		// Files that can't be downleveled are sent as they are.
+		if err := downlevelFileProto(file, fileProto); err != nil && report != nil {
+			report(err)
+		}
+	}
+	for _, transform := range r.transforms {
+		transform(fileProto)
+	}
+	return fileProto
+}
+
+// fileWithDependencies encodes the file and its dependencies, as configured
+// for the stream.
+func (r *Reflector) fileWithDependencies(fd protoreflect.FileDescriptor, state *streamState) ([][]byte, error) {
+	if state.strict && !fd.IsPlaceholder() {
+		if err := checkMissingImports(fd); err != nil {
+			return nil, newMissingImportsError(err)
+		}
+	}
+	return fileDescriptorWithDependencies(fd, state.dependencies, state.sentFiles(), state.maxResponseSize, r.encodeFile)
+}
+
+// fileDescriptorWithDependencies encodes the root file, along with the imports
+// selected by dependencies that haven't been sent yet. If sent is nil, imports
+// are sent regardless. If maxSize is positive and the encoded files would be
+// larger, it returns an error and leaves the set of sent files unchanged.
+func fileDescriptorWithDependencies(
+	rootFile protoreflect.FileDescriptor,
+	dependencies FileDependencies,
+	sent *fileDescriptorNameSet,
+	maxSize int,
+	encode func(protoreflect.FileDescriptor) ([]byte, error),
+) ([][]byte, error) {
 	if rootFile.IsPlaceholder() {
 		// A placeholder is used when a dependency is missing. If a placeholder is all we have
 		// then we don't actually have anything.
 		return nil, protoregistry.NotFound
 	}
+	if sent == nil {
+		sent = &fileDescriptorNameSet{}
+	}
+	maxDepth := dependencies.maxDepth()
 	results := make([][]byte, 0, 1)
-	queue := []protoreflect.FileDescriptor{rootFile}
+	var size int
+	var inserted []protoreflect.FileDescriptor
+	type queued struct {
+		file  protoreflect.FileDescriptor
+		depth int
+	}
+	// Files may be imported many times in a large schema, so we only visit
+	// each one once.
+	visited := map[string]struct{}{rootFile.Path(): {}}
+	queue := []queued{{file: rootFile}}
 	for len(queue) > 0 {
-		curr := queue[0]
+		curr, depth := queue[0].file, queue[0].depth
 		queue = queue[1:]
 		if curr.IsPlaceholder() {
 			continue // don't bother serializing placeholders
@@ -359,30 +728,77 @@ func fileDescriptorWithDependencies(rootFile protoreflect.FileDescriptor, sent *
 		if len(results) == 0 || !sent.Contains(curr) { // always send root fd
 			// Mark as sent immediately. If we hit an error marshaling below, there's
 			// no point trying again later.
-			sent.Insert(curr)
-			encoded, err := proto.Marshal(protodesc.ToFileDescriptorProto(curr))
+			if !sent.Contains(curr) {
+				sent.Insert(curr)
+				inserted = append(inserted, curr)
+			}
+			encoded, err := encode(curr)
 			if err != nil {
 				return nil, err
 			}
+			size += len(encoded)
+			if maxSize > 0 && size > maxSize {
+				// The client won't receive any of these files, so it may ask for
+				// them again.
+				for _, file := range inserted {
+					sent.Remove(file)
+				}
+				return nil, newResponseTooLargeError(maxSize)
+			}
 			results = append(results, encoded)
 		}
+		if maxDepth >= 0 && depth >= maxDepth {
+			continue
+		}
 		imports := curr.Imports()
 		for i := range imports.Len() {
-			queue = append(queue, imports.Get(i).FileDescriptor)
+			imported := imports.Get(i).FileDescriptor
+			if _, ok := visited[imported.Path()]; ok {
+				continue
+			}
+			visited[imported.Path()] = struct{}{}
+			queue = append(queue, queued{file: imported, depth: depth + 1})
 		}
 	}
 	return results, nil
 }
 
-func newNotFoundResponse(err error) *reflectionv1.ServerReflectionResponse_ErrorResponse {
+// newResolverErrorResponse answers a request that failed because of err,
+// typically returned by one of the Reflector's resolvers.
+func newResolverErrorResponse(err error) *reflectionv1.ServerReflectionResponse_ErrorResponse {
+	return newErrorResponse(classifyError(err), err)
+}
+
+func newErrorResponse(code connect.Code, err error) *reflectionv1.ServerReflectionResponse_ErrorResponse {
 	return &reflectionv1.ServerReflectionResponse_ErrorResponse{
 		ErrorResponse: &reflectionv1.ErrorResponse{
-			ErrorCode:    int32(connect.CodeNotFound),
+			ErrorCode:    int32(code),
 			ErrorMessage: err.Error(),
 		},
 	}
 }
 
+// classifyError chooses the code reported to clients for an error. Resolvers
+// can choose the code themselves by returning a *connect.Error. Otherwise,
+// errors wrapping protoregistry.NotFound are reported as "Not Found", context
+// errors are reported as "Canceled" or "Deadline Exceeded", and everything
+// else (marshaling failures, corrupt descriptors, and so on) is reported as
+// an "Internal" error.
+func classifyError(err error) connect.Code {
+	var connectErr *connect.Error
+	switch {
+	case errors.As(err, &connectErr):
+		return connectErr.Code()
+	case errors.Is(err, protoregistry.NotFound):
+		return connect.CodeNotFound
+	case errors.Is(err, context.Canceled):
+		return connect.CodeCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		return connect.CodeDeadlineExceeded
+	}
+	return connect.CodeInternal
+}
+
 func newHandler(reflector *Reflector, servicePath string, options []connect.HandlerOption) (string, http.Handler) {
 	return servicePath, connect.NewBidiStreamHandler(
 		servicePath+methodName,
@@ -425,7 +841,9 @@ func (n *staticNames) Names() []string {
 //
 // This works by serving embedded descriptors (from "services.bin") for items not found in
 // protoregistry.GlobalFiles. The only thing in the embedded descriptors are for the health
-// and reflection services.
+// and reflection services. We can't alias the "connectext." descriptors in GlobalFiles with
+// an AliasResolver instead, because the health service isn't compiled into this package.
+// Applications that link in their own renamed copies of a schema should use AliasResolver.
 func resolverHackForConnectext(data []byte) protodesc.Resolver {
 	var backupResolver protodesc.Resolver
 	var fileSet descriptorpb.FileDescriptorSet
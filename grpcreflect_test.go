@@ -16,10 +16,14 @@ package grpcreflect
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 
 	"connectrpc.com/connect"
@@ -59,15 +63,121 @@ func TestReflection(t *testing.T) {
 	})
 }
 
-func testReflector(t *testing.T, reflector *Reflector, servicePath string) {
+func TestNewHandler(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name       string
+		prefix     string
+		mountPath  string
+		clientPath string
+	}{
+		{name: "prefix", prefix: "/internal", mountPath: "/internal/", clientPath: "/internal"},
+		{name: "unrooted_prefix", prefix: "internal/", mountPath: "/internal/", clientPath: "/internal/"},
+		{name: "nested_prefix", prefix: "/debug/grpc/", mountPath: "/debug/grpc/", clientPath: "/debug/grpc"},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			path, handler := NewHandler(testCase.prefix, NewStaticReflector(actualServiceName))
+			if path != testCase.mountPath {
+				t.Fatalf("unexpected mount path: want %q ; got %q", testCase.mountPath, path)
+			}
+			server := newTestServer(t, func(mux *http.ServeMux) {
+				mux.Handle(path, handler)
+			})
+			baseURL := server.URL + testCase.clientPath
+
+			stream := NewClient(server.Client(), baseURL, connect.WithGRPC()).NewStream(t.Context())
+			t.Cleanup(func() {
+				_, _ = stream.Close()
+			})
+			names, err := stream.ListServices()
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if len(names) != 1 || names[0] != actualServiceName {
+				t.Fatalf("unexpected services: %v", names)
+			}
+
+			prefix := strings.TrimRight(baseURL, "/")
+			for _, servicePath := range []string{serviceURLPathV1, serviceURLPathV1Alpha} {
+				client := connect.NewClient[reflectionv1.ServerReflectionRequest, reflectionv1.ServerReflectionResponse](
+					server.Client(),
+					prefix+servicePath+methodName,
+					connect.WithGRPC(),
+				)
+				req := &reflectionv1.ServerReflectionRequest{
+					MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+				}
+				if _, err := client.CallUnary(t.Context(), connect.NewRequest(req)); err != nil {
+					t.Fatalf("%s: unexpected err: %v", servicePath, err)
+				}
+			}
+
+			res, err := server.Client().Get(server.URL + path + "healthz")
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			_ = res.Body.Close()
+			if res.StatusCode != http.StatusNotFound {
+				t.Fatalf("expected other paths to be answered with 404, got %d", res.StatusCode)
+			}
+		})
+	}
+	for _, prefix := range []string{"", "/"} {
+		t.Run(fmt.Sprintf("no_prefix_%q", prefix), func(t *testing.T) {
+			t.Parallel()
+			defer func() {
+				if recover() == nil {
+					t.Fatal("expected NewHandler to panic without a prefix")
+				}
+			}()
+			NewHandler(prefix, NewStaticReflector(actualServiceName))
+		})
+	}
+}
+
+// newTestServer starts a TLS server that supports HTTP/2, with handlers
+// registered by register.
+func newTestServer(t *testing.T, register func(mux *http.ServeMux)) *httptest.Server {
 	t.Helper()
 	mux := http.NewServeMux()
-	mux.Handle(NewHandlerV1(reflector))
-	mux.Handle(NewHandlerV1Alpha(reflector))
+	register(mux)
 	server := httptest.NewUnstartedServer(mux)
 	server.EnableHTTP2 = true
 	server.StartTLS()
 	t.Cleanup(server.Close)
+	return server
+}
+
+// newTestClient starts a server with newTestServer and returns a gRPC client
+// for it.
+func newTestClient(t *testing.T, register func(mux *http.ServeMux)) *Client {
+	t.Helper()
+	server := newTestServer(t, register)
+	return NewClient(server.Client(), server.URL, connect.WithGRPC())
+}
+
+// newTestStream starts a server for the reflector's v1 handler and opens a
+// reflection stream to it, which is closed when the test ends.
+func newTestStream(t *testing.T, reflector *Reflector, options ...ClientStreamOption) *ClientStream {
+	t.Helper()
+	client := newTestClient(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(reflector))
+	})
+	stream := client.NewStream(t.Context(), options...)
+	t.Cleanup(func() {
+		_, _ = stream.Close()
+	})
+	return stream
+}
+
+func testReflector(t *testing.T, reflector *Reflector, servicePath string) {
+	t.Helper()
+	server := newTestServer(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(reflector))
+		mux.Handle(NewHandlerV1Alpha(reflector))
+	})
 
 	reflectionRequestFQN := string((&reflectionv1.ServerReflectionRequest{}).
 		ProtoReflect().
@@ -301,6 +411,51 @@ func testReflector(t *testing.T, reflector *Reflector, servicePath string) {
 	})
 }
 
+func TestErrorCodes(t *testing.T) {
+	t.Parallel()
+	resolver := errorsByPath{
+		"missing.proto":   protoregistry.NotFound,
+		"wrapped.proto":   fmt.Errorf("upstream: %w", protoregistry.NotFound),
+		"forbidden.proto": connect.NewError(connect.CodePermissionDenied, errors.New("not for you")),
+		"upstream.proto":  connect.NewError(connect.CodeUnavailable, errors.New("backend down")),
+		"slow.proto":      fmt.Errorf("fetching descriptor: %w", context.DeadlineExceeded),
+		"corrupt.proto":   errors.New("proto: cannot parse invalid wire-format data"),
+	}
+	expected := map[string]connect.Code{
+		"missing.proto":   connect.CodeNotFound,
+		"wrapped.proto":   connect.CodeNotFound,
+		"forbidden.proto": connect.CodePermissionDenied,
+		"upstream.proto":  connect.CodeUnavailable,
+		"slow.proto":      connect.CodeDeadlineExceeded,
+		"corrupt.proto":   connect.CodeInternal,
+	}
+	stream := newTestStream(t, NewReflector(&staticNames{}, WithDescriptorResolver(resolver)))
+	for path, code := range expected {
+		_, err := stream.FileByFilename(path)
+		if IsReflectionStreamBroken(err) {
+			t.Fatalf("%s: error should not break the stream: %v", path, err)
+		}
+		if connect.CodeOf(err) != code {
+			t.Errorf("%s: unexpected code: want %v, got %v (%v)", path, code, connect.CodeOf(err), err)
+		}
+	}
+}
+
+// errorsByPath is a descriptor resolver that fails to find each file with the
+// error for its path.
+type errorsByPath map[string]error
+
+func (e errorsByPath) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	if err, ok := e[path]; ok {
+		return nil, err
+	}
+	return nil, protoregistry.NotFound
+}
+
+func (e errorsByPath) FindDescriptorByName(protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return nil, protoregistry.NotFound
+}
+
 func TestFileDescriptorWithDependencies(t *testing.T) {
 	t.Parallel()
 
@@ -439,7 +594,7 @@ func TestFileDescriptorWithDependencies(t *testing.T) {
 				sent.Insert(dummyFile{path: path})
 			}
 
-			descriptors, err := fileDescriptorWithDependencies(testCase.root, sent)
+			descriptors, err := fileDescriptorWithDependencies(testCase.root, FileDependenciesTransitive, sent, 0, (&Reflector{}).encodeFile)
 			if len(testCase.expect) == 0 {
 				// if we're not expecting any files then we're expecting an error
 				if err == nil {
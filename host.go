@@ -0,0 +1,94 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"fmt"
+	"net"
+)
+
+// A HostRouter chooses the Reflector that answers reflection requests for a
+// virtual host. Clients name the host they're interested in with each request
+// (see WithReflectionHost), which lets a single reflection handler serve
+// different schemas for different hosts. HostRouters must be safe to call
+// concurrently.
+//
+// ReflectorForHost returns nil if the host is unknown.
+type HostRouter interface {
+	ReflectorForHost(host string) *Reflector
+}
+
+// HostRouterFunc is an adapter to allow the use of an ordinary function as a
+// HostRouter.
+type HostRouterFunc func(host string) *Reflector
+
+// ReflectorForHost returns the Reflector for the host, implements the
+// HostRouter interface.
+func (f HostRouterFunc) ReflectorForHost(host string) *Reflector {
+	return f(host)
+}
+
+// HostMap is a HostRouter backed by a map from host names to Reflectors. If a
+// host includes a port and isn't in the map, HostMap also looks up the host
+// without its port.
+type HostMap map[string]*Reflector
+
+// ReflectorForHost returns the Reflector for the host, implements the
+// HostRouter interface.
+func (m HostMap) ReflectorForHost(host string) *Reflector {
+	if reflector, ok := m[host]; ok {
+		return reflector
+	}
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		return m[hostname]
+	}
+	return nil
+}
+
+// WithHostRouter routes each reflection request to the Reflector chosen by the
+// router for the request's host. Requests for hosts that the router doesn't
+// know are answered with a "Not Found" error. Requests that don't name a host
+// (which is the norm for most tools) and that the router doesn't handle are
+// answered by the Reflector being configured.
+//
+// Routing isn't recursive: any HostRouter configured on the Reflectors returned
+// by the router is ignored. By default, Reflectors don't route requests and
+// answer requests for all hosts themselves.
+func WithHostRouter(router HostRouter) Option {
+	return &hostRouterOption{router: router}
+}
+
+// route returns the Reflector that should answer requests for the given host,
+// along with the host to report back to the client.
+func (r *Reflector) route(host string) (*Reflector, string, error) {
+	if r.hostRouter == nil {
+		return r, host, nil
+	}
+	if reflector := r.hostRouter.ReflectorForHost(host); reflector != nil {
+		return reflector, host, nil
+	}
+	if host == "" {
+		return r, "", nil
+	}
+	return nil, "", fmt.Errorf("unknown host %q", host)
+}
+
+type hostRouterOption struct {
+	router HostRouter
+}
+
+func (o *hostRouterOption) apply(reflector *Reflector) {
+	reflector.hostRouter = o.router
+}
@@ -0,0 +1,116 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+	"github.com/google/go-cmp/cmp"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/testing/protocmp"
+)
+
+func TestHostRouter(t *testing.T) {
+	t.Parallel()
+	const testServiceName = "connect.reflecttest.v1.TestService"
+	reflector := NewReflector(
+		&staticNames{names: []string{actualServiceName}},
+		WithHostRouter(HostMap{
+			"api.example.com": NewStaticReflector(testServiceName),
+		}),
+	)
+	server := newTestServer(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(reflector))
+	})
+	client := NewClient(server.Client(), server.URL, connect.WithGRPC())
+
+	listServices := func(t *testing.T, host string) ([]protoreflect.FullName, error) {
+		t.Helper()
+		stream := client.NewStream(t.Context(), WithReflectionHost(host))
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		return stream.ListServices()
+	}
+
+	t.Run("routed", func(t *testing.T) {
+		t.Parallel()
+		for _, host := range []string{"api.example.com", "api.example.com:443"} {
+			names, err := listServices(t, host)
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if expected := []protoreflect.FullName{testServiceName}; !reflect.DeepEqual(expected, names) {
+				t.Fatalf("unexpected names for %q: want %v ; got %v", host, expected, names)
+			}
+		}
+	})
+	t.Run("no_host", func(t *testing.T) {
+		t.Parallel()
+		names, err := listServices(t, "")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if expected := []protoreflect.FullName{actualServiceName}; !reflect.DeepEqual(expected, names) {
+			t.Fatalf("unexpected names: want %v ; got %v", expected, names)
+		}
+	})
+	t.Run("unknown_host", func(t *testing.T) {
+		t.Parallel()
+		_, err := listServices(t, "other.example.com")
+		if IsReflectionStreamBroken(err) {
+			t.Fatalf("error should not break the stream: %v", err)
+		}
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("unexpected code: want %v, got %v", connect.CodeNotFound, connect.CodeOf(err))
+		}
+	})
+	t.Run("valid_host", func(t *testing.T) {
+		t.Parallel()
+		rawClient := connect.NewClient[
+			reflectionv1.ServerReflectionRequest,
+			reflectionv1.ServerReflectionResponse,
+		](
+			server.Client(),
+			server.URL+serviceURLPathV1+methodName,
+			connect.WithGRPC(),
+		)
+		for host, validHost := range map[string]string{
+			"api.example.com":   "api.example.com",
+			"":                  "",
+			"other.example.com": "",
+		} {
+			req := &reflectionv1.ServerReflectionRequest{
+				Host:           host,
+				MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+			}
+			res, err := rawClient.CallUnary(t.Context(), connect.NewRequest(req))
+			if err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+			if res.Msg.ValidHost != validHost {
+				t.Fatalf("unexpected valid host for %q: want %q, got %q", host, validHost, res.Msg.ValidHost)
+			}
+			if diff := cmp.Diff(req, res.Msg.OriginalRequest, protocmp.Transform()); diff != "" {
+				t.Fatal(diff)
+			}
+		}
+	})
+}
@@ -0,0 +1,236 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.5
+// 	protoc        (unknown)
+// source: connect/grpcreflect/v1/batch.proto
+
+package grpcreflectv1
+
+import (
+	v1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type BatchServerReflectionInfoRequest struct {
+	state         protoimpl.MessageState        `protogen:"open.v1"`
+	Requests      []*v1.ServerReflectionRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchServerReflectionInfoRequest) Reset() {
+	*x = BatchServerReflectionInfoRequest{}
+	mi := &file_connect_grpcreflect_v1_batch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchServerReflectionInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchServerReflectionInfoRequest) ProtoMessage() {}
+
+func (x *BatchServerReflectionInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_connect_grpcreflect_v1_batch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchServerReflectionInfoRequest.ProtoReflect.Descriptor instead.
+func (*BatchServerReflectionInfoRequest) Descriptor() ([]byte, []int) {
+	return file_connect_grpcreflect_v1_batch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BatchServerReflectionInfoRequest) GetRequests() []*v1.ServerReflectionRequest {
+	if x != nil {
+		return x.Requests
+	}
+	return nil
+}
+
+type BatchServerReflectionInfoResponse struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The responses, in the same order as the requests.
+	Responses     []*v1.ServerReflectionResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchServerReflectionInfoResponse) Reset() {
+	*x = BatchServerReflectionInfoResponse{}
+	mi := &file_connect_grpcreflect_v1_batch_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchServerReflectionInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchServerReflectionInfoResponse) ProtoMessage() {}
+
+func (x *BatchServerReflectionInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_connect_grpcreflect_v1_batch_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchServerReflectionInfoResponse.ProtoReflect.Descriptor instead.
+func (*BatchServerReflectionInfoResponse) Descriptor() ([]byte, []int) {
+	return file_connect_grpcreflect_v1_batch_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *BatchServerReflectionInfoResponse) GetResponses() []*v1.ServerReflectionResponse {
+	if x != nil {
+		return x.Responses
+	}
+	return nil
+}
+
+var File_connect_grpcreflect_v1_batch_proto protoreflect.FileDescriptor
+
+var file_connect_grpcreflect_v1_batch_proto_rawDesc = string([]byte{
+	0x0a, 0x22, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65,
+	0x66, 0x6c, 0x65, 0x63, 0x74, 0x2f, 0x76, 0x31, 0x2f, 0x62, 0x61, 0x74, 0x63, 0x68, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2e, 0x76, 0x31, 0x1a, 0x2e, 0x63, 0x6f,
+	0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x78, 0x74, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x2f, 0x72, 0x65,
+	0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2f, 0x76, 0x31, 0x2f, 0x72, 0x65, 0x66, 0x6c,
+	0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0x76, 0x0a, 0x20,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x52, 0x0a, 0x08, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x73, 0x18, 0x01, 0x20, 0x03,
+	0x28, 0x0b, 0x32, 0x36, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x78, 0x74, 0x2e,
+	0x67, 0x72, 0x70, 0x63, 0x2e, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e,
+	0x76, 0x31, 0x2e, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x52, 0x08, 0x72, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x73, 0x22, 0x7a, 0x0a, 0x21, 0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x66,
+	0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x55, 0x0a, 0x09, 0x72, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x37, 0x2e, 0x63,
+	0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x65, 0x78, 0x74, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x2e, 0x72,
+	0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x65, 0x72,
+	0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x52, 0x09, 0x72, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x73,
+	0x32, 0xb0, 0x01, 0x0a, 0x16, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x66, 0x6c, 0x65, 0x63,
+	0x74, 0x69, 0x6f, 0x6e, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x95, 0x01, 0x0a, 0x19,
+	0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65,
+	0x63, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x38, 0x2e, 0x63, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2e,
+	0x76, 0x31, 0x2e, 0x42, 0x61, 0x74, 0x63, 0x68, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x65,
+	0x66, 0x6c, 0x65, 0x63, 0x74, 0x69, 0x6f, 0x6e, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x39, 0x2e, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x2e, 0x67, 0x72,
+	0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2e, 0x76, 0x31, 0x2e, 0x42, 0x61, 0x74,
+	0x63, 0x68, 0x53, 0x65, 0x72, 0x76, 0x65, 0x72, 0x52, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x69,
+	0x6f, 0x6e, 0x49, 0x6e, 0x66, 0x6f, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x03,
+	0x90, 0x02, 0x01, 0x42, 0xf3, 0x01, 0x0a, 0x1a, 0x63, 0x6f, 0x6d, 0x2e, 0x63, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x2e, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2e,
+	0x76, 0x31, 0x42, 0x0a, 0x42, 0x61, 0x74, 0x63, 0x68, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x50, 0x01,
+	0x5a, 0x4f, 0x63, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x72, 0x70, 0x63, 0x2e, 0x63, 0x6f, 0x6d,
+	0x2f, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2f, 0x69, 0x6e, 0x74,
+	0x65, 0x72, 0x6e, 0x61, 0x6c, 0x2f, 0x67, 0x65, 0x6e, 0x2f, 0x67, 0x6f, 0x2f, 0x63, 0x6f, 0x6e,
+	0x6e, 0x65, 0x63, 0x74, 0x2f, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74,
+	0x2f, 0x76, 0x31, 0x3b, 0x67, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x76,
+	0x31, 0xa2, 0x02, 0x03, 0x43, 0x47, 0x58, 0xaa, 0x02, 0x16, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63,
+	0x74, 0x2e, 0x47, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x2e, 0x56, 0x31,
+	0xca, 0x02, 0x16, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x5c, 0x47, 0x72, 0x70, 0x63, 0x72,
+	0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x5c, 0x56, 0x31, 0xe2, 0x02, 0x22, 0x43, 0x6f, 0x6e, 0x6e,
+	0x65, 0x63, 0x74, 0x5c, 0x47, 0x72, 0x70, 0x63, 0x72, 0x65, 0x66, 0x6c, 0x65, 0x63, 0x74, 0x5c,
+	0x56, 0x31, 0x5c, 0x47, 0x50, 0x42, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0xea, 0x02,
+	0x18, 0x43, 0x6f, 0x6e, 0x6e, 0x65, 0x63, 0x74, 0x3a, 0x3a, 0x47, 0x72, 0x70, 0x63, 0x72, 0x65,
+	0x66, 0x6c, 0x65, 0x63, 0x74, 0x3a, 0x3a, 0x56, 0x31, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x33,
+})
+
+var (
+	file_connect_grpcreflect_v1_batch_proto_rawDescOnce sync.Once
+	file_connect_grpcreflect_v1_batch_proto_rawDescData []byte
+)
+
+func file_connect_grpcreflect_v1_batch_proto_rawDescGZIP() []byte {
+	file_connect_grpcreflect_v1_batch_proto_rawDescOnce.Do(func() {
+		file_connect_grpcreflect_v1_batch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_connect_grpcreflect_v1_batch_proto_rawDesc), len(file_connect_grpcreflect_v1_batch_proto_rawDesc)))
+	})
+	return file_connect_grpcreflect_v1_batch_proto_rawDescData
+}
+
+var file_connect_grpcreflect_v1_batch_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_connect_grpcreflect_v1_batch_proto_goTypes = []any{
+	(*BatchServerReflectionInfoRequest)(nil),  // 0: connect.grpcreflect.v1.BatchServerReflectionInfoRequest
+	(*BatchServerReflectionInfoResponse)(nil), // 1: connect.grpcreflect.v1.BatchServerReflectionInfoResponse
+	(*v1.ServerReflectionRequest)(nil),        // 2: connectext.grpc.reflection.v1.ServerReflectionRequest
+	(*v1.ServerReflectionResponse)(nil),       // 3: connectext.grpc.reflection.v1.ServerReflectionResponse
+}
+var file_connect_grpcreflect_v1_batch_proto_depIdxs = []int32{
+	2, // 0: connect.grpcreflect.v1.BatchServerReflectionInfoRequest.requests:type_name -> connectext.grpc.reflection.v1.ServerReflectionRequest
+	3, // 1: connect.grpcreflect.v1.BatchServerReflectionInfoResponse.responses:type_name -> connectext.grpc.reflection.v1.ServerReflectionResponse
+	0, // 2: connect.grpcreflect.v1.BatchReflectionService.BatchServerReflectionInfo:input_type -> connect.grpcreflect.v1.BatchServerReflectionInfoRequest
+	1, // 3: connect.grpcreflect.v1.BatchReflectionService.BatchServerReflectionInfo:output_type -> connect.grpcreflect.v1.BatchServerReflectionInfoResponse
+	3, // [3:4] is the sub-list for method output_type
+	2, // [2:3] is the sub-list for method input_type
+	2, // [2:2] is the sub-list for extension type_name
+	2, // [2:2] is the sub-list for extension extendee
+	0, // [0:2] is the sub-list for field type_name
+}
+
+func init() { file_connect_grpcreflect_v1_batch_proto_init() }
+func file_connect_grpcreflect_v1_batch_proto_init() {
+	if File_connect_grpcreflect_v1_batch_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_connect_grpcreflect_v1_batch_proto_rawDesc), len(file_connect_grpcreflect_v1_batch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_connect_grpcreflect_v1_batch_proto_goTypes,
+		DependencyIndexes: file_connect_grpcreflect_v1_batch_proto_depIdxs,
+		MessageInfos:      file_connect_grpcreflect_v1_batch_proto_msgTypes,
+	}.Build()
+	File_connect_grpcreflect_v1_batch_proto = out.File
+	file_connect_grpcreflect_v1_batch_proto_goTypes = nil
+	file_connect_grpcreflect_v1_batch_proto_depIdxs = nil
+}
@@ -0,0 +1,227 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"fmt"
+	"time"
+
+	"connectrpc.com/connect"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+)
+
+// The limits below protect public reflection endpoints from clients that hold
+// streams open indefinitely or use them to download huge schemas over and over.
+// Limits are enforced by the Reflector used to build the handler, even for
+// requests routed to other Reflectors by a HostRouter. By default, Reflectors
+// don't limit anything.
+
+// WithMaxRequestsPerStream limits the number of requests a client may send on
+// a single reflection stream. Once the limit is reached, the next request
+// terminates the stream with a "Resource Exhausted" error, and the client must
+// open a new stream. If maxRequests is zero or negative, the number of
+// requests is unlimited.
+func WithMaxRequestsPerStream(maxRequests int) Option {
+	return &maxRequestsPerStreamOption{maxRequests: maxRequests}
+}
+
+// WithMaxBytesPerStream limits the total size of the file descriptors sent on
+// a single reflection stream. A request whose response would exceed the limit
+// terminates the stream with a "Resource Exhausted" error, and the client must
+// open a new stream. If maxBytes is zero or negative, the total size is
+// unlimited.
+func WithMaxBytesPerStream(maxBytes int) Option {
+	return &maxBytesPerStreamOption{maxBytes: maxBytes}
+}
+
+// WithMaxResponseSize limits the total size of the file descriptors sent in
+// response to a single request, including the dependencies of the requested
+// file. Requests whose responses would exceed the limit are answered with a
+// "Resource Exhausted" error, but the stream remains usable. If maxBytes is
+// zero or negative, the size of responses is unlimited.
+func WithMaxResponseSize(maxBytes int) Option {
+	return &maxResponseSizeOption{maxBytes: maxBytes}
+}
+
+// WithIdleTimeout terminates reflection streams with a "Deadline Exceeded"
+// error if the client doesn't send a request within the timeout (measured
+// from the start of the stream or the previous response). If timeout is zero
+// or negative, streams may be idle indefinitely.
+func WithIdleTimeout(timeout time.Duration) Option {
+	return &idleTimeoutOption{timeout: timeout}
+}
+
+// WithMaxConcurrentStreams limits the number of reflection streams the
+// Reflector serves at once. Streams opened beyond the limit fail immediately
+// with a "Resource Exhausted" error. If maxStreams is zero or negative, the
+// number of concurrent streams is unlimited.
+func WithMaxConcurrentStreams(maxStreams int) Option {
+	return &maxConcurrentStreamsOption{maxStreams: maxStreams}
+}
+
+// acquireStream reserves one of the Reflector's concurrent streams. If it
+// succeeds, the caller must call releaseStream when the stream is done.
+func (r *Reflector) acquireStream() error {
+	if r.maxStreams <= 0 {
+		return nil
+	}
+	if r.activeStreams.Add(1) > int64(r.maxStreams) {
+		r.activeStreams.Add(-1)
+		return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf(
+			"too many reflection streams: limit is %d", r.maxStreams,
+		))
+	}
+	return nil
+}
+
+func (r *Reflector) releaseStream() {
+	if r.maxStreams > 0 {
+		r.activeStreams.Add(-1)
+	}
+}
+
+// streamUsage tracks a stream's progress toward the Reflector's per-stream
+// limits.
+type streamUsage struct {
+	requests int
+	bytes    int
+}
+
+// addRequest records a request, returning an error if it exceeds the
+// Reflector's limit.
+func (r *Reflector) addRequest(usage *streamUsage) error {
+	usage.requests++
+	if r.maxRequestsPerStream > 0 && usage.requests > r.maxRequestsPerStream {
+		return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf(
+			"too many requests on reflection stream: limit is %d", r.maxRequestsPerStream,
+		))
+	}
+	return nil
+}
+
+// addBytes records the file descriptors sent in a response, returning an error
+// if they exceed the Reflector's limit.
+func (r *Reflector) addBytes(usage *streamUsage, bytes int) error {
+	usage.bytes += bytes
+	if r.maxBytesPerStream > 0 && usage.bytes > r.maxBytesPerStream {
+		return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf(
+			"too many bytes sent on reflection stream: limit is %d", r.maxBytesPerStream,
+		))
+	}
+	return nil
+}
+
+// newResponseTooLargeError reports that a response would exceed the maximum
+// size.
+func newResponseTooLargeError(maxBytes int) error {
+	return connect.NewError(connect.CodeResourceExhausted, fmt.Errorf(
+		"response would exceed the limit of %d bytes", maxBytes,
+	))
+}
+
+type receiveResult struct {
+	request *reflectionv1.ServerReflectionRequest
+	err     error
+}
+
+// idleReceiver receives requests from a stream in a separate goroutine, so
+// that waiting for them can time out.
+type idleReceiver struct {
+	timeout time.Duration
+	results chan receiveResult
+	done    chan struct{}
+}
+
+func newIdleReceiver(receive func() (*reflectionv1.ServerReflectionRequest, error), timeout time.Duration) *idleReceiver {
+	receiver := &idleReceiver{
+		timeout: timeout,
+		results: make(chan receiveResult),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		for {
+			request, err := receive()
+			select {
+			case receiver.results <- receiveResult{request: request, err: err}:
+			case <-receiver.done:
+				return
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return receiver
+}
+
+func (r *idleReceiver) Receive() (*reflectionv1.ServerReflectionRequest, error) {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+	select {
+	case result := <-r.results:
+		return result.request, result.err
+	case <-timer.C:
+		return nil, connect.NewError(connect.CodeDeadlineExceeded, fmt.Errorf(
+			"no request received on reflection stream in %v", r.timeout,
+		))
+	}
+}
+
+// Close stops the receiving goroutine. It's blocked until the stream's next
+// request arrives or the stream ends, which happens as soon as the handler
+// returns.
+func (r *idleReceiver) Close() {
+	close(r.done)
+}
+
+type maxRequestsPerStreamOption struct {
+	maxRequests int
+}
+
+func (o *maxRequestsPerStreamOption) apply(reflector *Reflector) {
+	reflector.maxRequestsPerStream = o.maxRequests
+}
+
+type maxBytesPerStreamOption struct {
+	maxBytes int
+}
+
+func (o *maxBytesPerStreamOption) apply(reflector *Reflector) {
+	reflector.maxBytesPerStream = o.maxBytes
+}
+
+type maxResponseSizeOption struct {
+	maxBytes int
+}
+
+func (o *maxResponseSizeOption) apply(reflector *Reflector) {
+	reflector.maxResponseSize = o.maxBytes
+}
+
+type idleTimeoutOption struct {
+	timeout time.Duration
+}
+
+func (o *idleTimeoutOption) apply(reflector *Reflector) {
+	reflector.idleTimeout = o.timeout
+}
+
+type maxConcurrentStreamsOption struct {
+	maxStreams int
+}
+
+func (o *maxConcurrentStreamsOption) apply(reflector *Reflector) {
+	reflector.maxStreams = o.maxStreams
+}
@@ -0,0 +1,132 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestLimits(t *testing.T) {
+	t.Parallel()
+	newClient := func(t *testing.T, options ...Option) *Client {
+		t.Helper()
+		reflector := NewReflector(&staticNames{names: []string{actualServiceName}}, options...)
+		return newTestClient(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(reflector))
+		})
+	}
+	newStream := func(t *testing.T, client *Client) *ClientStream {
+		t.Helper()
+		stream := client.NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = stream.Close()
+		})
+		return stream
+	}
+	assertBroken := func(t *testing.T, err error, code connect.Code) {
+		t.Helper()
+		if !IsReflectionStreamBroken(err) {
+			t.Fatalf("expected broken stream, got %v", err)
+		}
+		if connect.CodeOf(err) != code {
+			t.Fatalf("unexpected code: want %v, got %v (%v)", code, connect.CodeOf(err), err)
+		}
+	}
+
+	t.Run("requests_per_stream", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, newClient(t, WithMaxRequestsPerStream(2)))
+		for range 2 {
+			if _, err := stream.ListServices(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		}
+		_, err := stream.ListServices()
+		assertBroken(t, err, connect.CodeResourceExhausted)
+	})
+	t.Run("bytes_per_stream", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, newClient(t, WithMaxBytesPerStream(100)))
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, err := stream.FileContainingSymbol(actualServiceName)
+		assertBroken(t, err, connect.CodeResourceExhausted)
+	})
+	t.Run("response_size", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, newClient(t, WithMaxResponseSize(100)))
+		_, err := stream.FileContainingSymbol(actualServiceName)
+		if IsReflectionStreamBroken(err) {
+			t.Fatalf("error should not break the stream: %v", err)
+		}
+		if connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Fatalf("unexpected code: want %v, got %v (%v)", connect.CodeResourceExhausted, connect.CodeOf(err), err)
+		}
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+	t.Run("response_size_rollback", func(t *testing.T) {
+		t.Parallel()
+		file, err := protoregistry.GlobalFiles.FindFileByPath("connect/reflecttest/v1/reflecttest_ext.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		encode := (&Reflector{}).encodeFile
+		sent := &fileDescriptorNameSet{}
+		if _, err := fileDescriptorWithDependencies(file, FileDependenciesTransitive, sent, 10, encode); connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		// Since nothing was sent, nothing should be deduplicated.
+		descriptors, err := fileDescriptorWithDependencies(file, FileDependenciesTransitive, sent, 0, encode)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		checkDescriptorResults(t, descriptors, []string{
+			"connect/reflecttest/v1/reflecttest_ext.proto",
+			"connect/reflecttest/v1/reflecttest.proto",
+		})
+	})
+	t.Run("idle_timeout", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, newClient(t, WithIdleTimeout(50*time.Millisecond)))
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		time.Sleep(200 * time.Millisecond)
+		_, err := stream.ListServices()
+		assertBroken(t, err, connect.CodeDeadlineExceeded)
+	})
+	t.Run("concurrent_streams", func(t *testing.T) {
+		t.Parallel()
+		client := newClient(t, WithMaxConcurrentStreams(1))
+		first := newStream(t, client)
+		if _, err := first.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, err := newStream(t, client).ListServices()
+		assertBroken(t, err, connect.CodeResourceExhausted)
+		if _, err := first.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+}
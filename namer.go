@@ -0,0 +1,158 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A FileRanger iterates over a set of files. *protoregistry.Files implements
+// FileRanger.
+type FileRanger interface {
+	RangeFiles(f func(protoreflect.FileDescriptor) bool)
+}
+
+// RegistryNamerOption configures a Namer created with NewRegistryNamer.
+type RegistryNamerOption interface {
+	apply(*registryNamer)
+}
+
+// NewRegistryNamer constructs a Namer that lists every service defined in the
+// given files, which is convenient for internal tools and debugging binaries.
+// For example, to list every service compiled into the binary:
+//
+//	namer := grpcreflect.NewRegistryNamer(
+//		protoregistry.GlobalFiles,
+//		grpcreflect.WithExcludedServices("grpc.*"),
+//	)
+//
+// The files are walked each time the Namer is called, so services registered
+// later are listed too. Services in packages starting with "connectext." are
+// never listed, since they're this package's private copies of gRPC's
+// services.
+func NewRegistryNamer(files FileRanger, options ...RegistryNamerOption) Namer {
+	namer := &registryNamer{files: files}
+	for _, option := range options {
+		option.apply(namer)
+	}
+	return namer
+}
+
+// WithIncludedServices limits a registry Namer to services whose
+// fully-qualified names match at least one of the patterns. In patterns, "*"
+// matches any sequence of characters (including dots), and all other
+// characters match themselves: "acme.*.v1.*" matches
+// "acme.user.v1.UserService". By default, all services are included.
+//
+// WithIncludedServices may be used more than once, and the patterns are
+// combined.
+func WithIncludedServices(patterns ...string) RegistryNamerOption {
+	return &includedServicesOption{patterns: patterns}
+}
+
+// WithExcludedServices omits services whose fully-qualified names match any
+// of the patterns from a registry Namer, even if they're included by
+// WithIncludedServices. Patterns use the same syntax as WithIncludedServices.
+//
+// WithExcludedServices may be used more than once, and the patterns are
+// combined.
+func WithExcludedServices(patterns ...string) RegistryNamerOption {
+	return &excludedServicesOption{patterns: patterns}
+}
+
+type registryNamer struct {
+	files    FileRanger
+	includes []string
+	excludes []string
+}
+
+func (n *registryNamer) Names() []string {
+	var names []string
+	n.files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		services := file.Services()
+		for i := range services.Len() {
+			name := string(services.Get(i).FullName())
+			if n.shouldList(name) {
+				names = append(names, name)
+			}
+		}
+		return true
+	})
+	sort.Strings(names)
+	return names
+}
+
+func (n *registryNamer) shouldList(name string) bool {
+	if strings.HasPrefix(name, "connectext.") {
+		return false
+	}
+	for _, pattern := range n.excludes {
+		if matchGlob(pattern, name) {
+			return false
+		}
+	}
+	if len(n.includes) == 0 {
+		return true
+	}
+	for _, pattern := range n.includes {
+		if matchGlob(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchGlob reports whether name matches the pattern, in which "*" matches
+// any sequence of characters.
+func matchGlob(pattern, name string) bool {
+	literals := strings.Split(pattern, "*")
+	if len(literals) == 1 {
+		return pattern == name
+	}
+	prefix, suffix := literals[0], literals[len(literals)-1]
+	if len(name) < len(prefix)+len(suffix) || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, suffix) {
+		return false
+	}
+	// Match the literals between the first and last stars greedily from the
+	// left, which is enough to find a match if there is one.
+	middle := name[len(prefix) : len(name)-len(suffix)]
+	for _, literal := range literals[1 : len(literals)-1] {
+		i := strings.Index(middle, literal)
+		if i < 0 {
+			return false
+		}
+		middle = middle[i+len(literal):]
+	}
+	return true
+}
+
+type includedServicesOption struct {
+	patterns []string
+}
+
+func (o *includedServicesOption) apply(namer *registryNamer) {
+	namer.includes = append(namer.includes, o.patterns...)
+}
+
+type excludedServicesOption struct {
+	patterns []string
+}
+
+func (o *excludedServicesOption) apply(namer *registryNamer) {
+	namer.excludes = append(namer.excludes, o.patterns...)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestRegistryNamer(t *testing.T) {
+	t.Parallel()
+	files := &protoregistry.Files{}
+	for pkg, services := range map[string][]string{
+		"acme.user.v1":     {"UserService", "AdminService"},
+		"acme.billing.v1":  {"InvoiceService"},
+		"acme.billing.v2":  {"InvoiceService"},
+		"grpc.health.v1":   {"Health"},
+		"connectext.other": {"Hidden"},
+	} {
+		fileProto := &descriptorpb.FileDescriptorProto{
+			Name:    proto.String(pkg + ".proto"),
+			Package: proto.String(pkg),
+			Syntax:  proto.String("proto3"),
+		}
+		for _, service := range services {
+			fileProto.Service = append(fileProto.Service, &descriptorpb.ServiceDescriptorProto{Name: proto.String(service)})
+		}
+		file, err := protodesc.NewFile(fileProto, files)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		options  []RegistryNamerOption
+		expected []string
+	}{
+		{
+			name: "everything",
+			expected: []string{
+				"acme.billing.v1.InvoiceService",
+				"acme.billing.v2.InvoiceService",
+				"acme.user.v1.AdminService",
+				"acme.user.v1.UserService",
+				"grpc.health.v1.Health",
+			},
+		},
+		{
+			name:    "include",
+			options: []RegistryNamerOption{WithIncludedServices("acme.*.v1.*")},
+			expected: []string{
+				"acme.billing.v1.InvoiceService",
+				"acme.user.v1.AdminService",
+				"acme.user.v1.UserService",
+			},
+		},
+		{
+			name: "include_and_exclude",
+			options: []RegistryNamerOption{
+				WithIncludedServices("acme.*"),
+				WithExcludedServices("*.AdminService"),
+				WithExcludedServices("acme.billing.v1.*"),
+			},
+			expected: []string{
+				"acme.billing.v2.InvoiceService",
+				"acme.user.v1.UserService",
+			},
+		},
+		{
+			name:    "exact",
+			options: []RegistryNamerOption{WithIncludedServices("grpc.health.v1.Health")},
+			expected: []string{
+				"grpc.health.v1.Health",
+			},
+		},
+	}
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			names := NewRegistryNamer(files, testCase.options...).Names()
+			if !reflect.DeepEqual(testCase.expected, names) {
+				t.Fatalf("unexpected names: want %v ; got %v", testCase.expected, names)
+			}
+		})
+	}
+}
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		pattern, name string
+		match         bool
+	}{
+		{pattern: "*", name: "acme.v1.Foo", match: true},
+		{pattern: "grpc.*", name: "grpc.health.v1.Health", match: true},
+		{pattern: "grpc.*", name: "grpcx.v1.Foo", match: false},
+		{pattern: "acme.*.v1.*", name: "acme.user.v1.UserService", match: true},
+		{pattern: "acme.*.v1.*", name: "acme.user.v2.UserService", match: false},
+		{pattern: "*Service", name: "acme.v1.UserService", match: true},
+		{pattern: "a*a*a", name: "aa", match: false},
+		{pattern: "a*a*a", name: "aaa", match: true},
+		{pattern: "acme.v1.Foo", name: "acme.v1.Foo", match: true},
+		{pattern: "acme.v1.Foo", name: "acme.v1.FooBar", match: false},
+	}
+	for _, testCase := range testCases {
+		if match := matchGlob(testCase.pattern, testCase.name); match != testCase.match {
+			t.Errorf("matchGlob(%q, %q): want %v, got %v", testCase.pattern, testCase.name, testCase.match, match)
+		}
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"time"
+
+	"connectrpc.com/connect"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+)
+
+// RequestType identifies the kind of an individual request on a reflection
+// stream.
+type RequestType int
+
+// The kinds of reflection requests, one for each field of the
+// ServerReflectionRequest message's oneof.
+const (
+	RequestTypeFileByFilename RequestType = iota + 1
+	RequestTypeFileContainingSymbol
+	RequestTypeFileContainingExtension
+	RequestTypeAllExtensionNumbersOfType
+	RequestTypeListServices
+)
+
+// String returns the name of the request's field in the
+// ServerReflectionRequest message (for example, "file_by_filename"), which
+// makes a good metric label.
+func (t RequestType) String() string {
+	switch t {
+	case RequestTypeFileByFilename:
+		return "file_by_filename"
+	case RequestTypeFileContainingSymbol:
+		return "file_containing_symbol"
+	case RequestTypeFileContainingExtension:
+		return "file_containing_extension"
+	case RequestTypeAllExtensionNumbersOfType:
+		return "all_extension_numbers_of_type"
+	case RequestTypeListServices:
+		return "list_services"
+	}
+	return "unknown"
+}
+
+// Outcome summarizes how a reflection request was answered.
+type Outcome int
+
+const (
+	// OutcomeOK means that the request was answered successfully.
+	OutcomeOK Outcome = iota + 1
+	// OutcomeNotFound means that the requested file, symbol, extension, or
+	// host doesn't exist (or is hidden from the caller).
+	OutcomeNotFound
+	// OutcomeError means that the request failed for any other reason.
+	OutcomeError
+)
+
+// String returns "ok", "not_found", or "error".
+func (o Outcome) String() string {
+	switch o {
+	case OutcomeOK:
+		return "ok"
+	case OutcomeNotFound:
+		return "not_found"
+	case OutcomeError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// RequestEvent describes an individual request on a reflection stream, after
+// it's been answered.
+type RequestEvent struct {
+	// Type is the kind of request.
+	Type RequestType
+	// Argument is the file path, symbol name, or message name in the request.
+	// For file_containing_extension requests, it's the name of the extended
+	// message. It's empty for list_services requests.
+	Argument string
+	// ExtensionNumber is the extension number in file_containing_extension
+	// requests, and zero otherwise.
+	ExtensionNumber int32
+	// Host is the virtual host named in the request, if any.
+	Host string
+	// Outcome summarizes the response, and Err is the error that caused it if
+	// the outcome isn't OutcomeOK.
+	Outcome Outcome
+	Err     error
+	// Files is the number of file descriptors in the response, and Bytes is
+	// their total size. Dependencies that were already sent on the stream
+	// aren't sent again, so these don't always include every import. Both
+	// are zero if the request ended the stream, since no response was sent.
+	Files int
+	Bytes int
+	// Latency is the time spent answering the request. It doesn't include the
+	// time spent sending the response to the client.
+	Latency time.Duration
+}
+
+// An Observer is notified of each individual request on a reflection stream.
+// Connect interceptors only see reflection streams as a whole, so observers
+// are the best way to collect per-request metrics. The context passed to the
+// observer is the stream's context, so StreamInfoFromContext reports the
+// caller's address and headers (including the User-Agent).
+//
+// Observers also see requests that end the stream instead of getting a
+// response, like requests that exceed the limits set by
+// WithMaxRequestsPerStream or WithMaxBytesPerStream. Those events have an
+// error outcome and Err is the error that ended the stream.
+//
+// Observers are called synchronously, before the response is sent, so they
+// should be fast. They must be safe to call concurrently.
+type Observer interface {
+	ObserveRequest(ctx context.Context, event *RequestEvent)
+}
+
+// ObserverFunc is an adapter to allow the use of an ordinary function as an
+// Observer.
+type ObserverFunc func(ctx context.Context, event *RequestEvent)
+
+// ObserveRequest calls f(ctx, event), implements the Observer interface.
+func (f ObserverFunc) ObserveRequest(ctx context.Context, event *RequestEvent) {
+	f(ctx, event)
+}
+
+// WithObserver configures an Observer that's notified of every request
+// answered by the Reflector. The Observer of the Reflector used to build the
+// handler also sees requests answered by Reflectors chosen by a HostRouter,
+// along with requests for unknown hosts.
+//
+// By default, Reflectors don't have an Observer.
+func WithObserver(observer Observer) Option {
+	return &observerOption{observer: observer}
+}
+
+// observeRequest completes the event using the response and reports it to the
+// Reflector's Observer, if any.
+func (r *Reflector) observeRequest(
+	ctx context.Context,
+	event *RequestEvent,
+	start time.Time,
+	response *reflectionv1.ServerReflectionResponse,
+) {
+	if r.observer == nil {
+		return
+	}
+	event.Latency = time.Since(start)
+	event.Outcome = OutcomeOK
+	if errorResponse := response.GetErrorResponse(); errorResponse != nil {
+		event.Outcome = OutcomeError
+		if connect.Code(errorResponse.GetErrorCode()) == connect.CodeNotFound {
+			event.Outcome = OutcomeNotFound
+		}
+	}
+	r.observer.ObserveRequest(ctx, event)
+}
+
+// observeFailure completes the event for a request that ended the stream with
+// the given error, and reports it to the Reflector's Observer, if any.
+func (r *Reflector) observeFailure(ctx context.Context, event *RequestEvent, start time.Time, err error) {
+	if r.observer == nil {
+		return
+	}
+	event.Latency = time.Since(start)
+	event.Err = err
+	event.Outcome = OutcomeError
+	if classifyError(err) == connect.CodeNotFound {
+		event.Outcome = OutcomeNotFound
+	}
+	event.Files = 0
+	event.Bytes = 0
+	r.observer.ObserveRequest(ctx, event)
+}
+
+// setFiles records the number and size of the file descriptors in a response.
+func (e *RequestEvent) setFiles(data [][]byte) {
+	e.Files = len(data)
+	for _, file := range data {
+		e.Bytes += len(file)
+	}
+}
+
+type observerOption struct {
+	observer Observer
+}
+
+func (o *observerOption) apply(reflector *Reflector) {
+	reflector.observer = o.observer
+}
@@ -0,0 +1,168 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+func TestObserver(t *testing.T) {
+	t.Parallel()
+	var (
+		mu     sync.Mutex
+		events []RequestEvent
+		agents []string
+	)
+	observer := ObserverFunc(func(ctx context.Context, event *RequestEvent) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, *event)
+		if info, ok := StreamInfoFromContext(ctx); ok {
+			agents = append(agents, info.RequestHeader.Get("User-Agent"))
+		}
+	})
+	reflector := NewReflector(
+		&staticNames{names: []string{actualServiceName}},
+		WithExtensionResolver(protoregistry.GlobalTypes),
+		WithObserver(observer),
+	)
+	stream := newTestStream(t, reflector, WithRequestHeaders(http.Header{"User-Agent": []string{"grpcurl/1.9"}}))
+
+	if _, err := stream.ListServices(); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := stream.FileByFilename("connect/reflecttest/v1/reflecttest_ext.proto"); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// The dependency was already sent, so only the requested file is sent again.
+	if _, err := stream.FileContainingExtension("connect.reflecttest.v1.Extendable", 10); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if _, err := stream.FileContainingSymbol("something.Thing"); connect.CodeOf(err) != connect.CodeNotFound {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []RequestEvent{
+		{Type: RequestTypeListServices, Outcome: OutcomeOK},
+		{Type: RequestTypeFileByFilename, Argument: "connect/reflecttest/v1/reflecttest_ext.proto", Outcome: OutcomeOK, Files: 2},
+		{Type: RequestTypeFileContainingExtension, Argument: "connect.reflecttest.v1.Extendable", ExtensionNumber: 10, Outcome: OutcomeOK, Files: 1},
+		{Type: RequestTypeFileContainingSymbol, Argument: "something.Thing", Outcome: OutcomeNotFound},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %v", len(expected), len(events), events)
+	}
+	for i, event := range events {
+		want := expected[i]
+		if event.Type != want.Type || event.Argument != want.Argument ||
+			event.ExtensionNumber != want.ExtensionNumber || event.Outcome != want.Outcome ||
+			event.Files != want.Files {
+			t.Errorf("event %d: want %+v, got %+v", i, want, event)
+		}
+		if (event.Files == 0) != (event.Bytes == 0) {
+			t.Errorf("event %d: sent %d files totaling %d bytes", i, event.Files, event.Bytes)
+		}
+		if (event.Outcome == OutcomeOK) != (event.Err == nil) {
+			t.Errorf("event %d: outcome %v with error %v", i, event.Outcome, event.Err)
+		}
+		if event.Latency <= 0 {
+			t.Errorf("event %d: expected positive latency", i)
+		}
+		if agents[i] != "grpcurl/1.9" {
+			t.Errorf("event %d: unexpected user agent %q", i, agents[i])
+		}
+	}
+	if last := events[len(events)-1]; !errors.Is(last.Err, protoregistry.NotFound) {
+		t.Errorf("expected protoregistry.NotFound, got %v", last.Err)
+	}
+}
+
+func TestObserverWithLimits(t *testing.T) {
+	t.Parallel()
+	newStream := func(t *testing.T, option Option) (*ClientStream, func() []RequestEvent) {
+		t.Helper()
+		var (
+			mu     sync.Mutex
+			events []RequestEvent
+		)
+		observer := ObserverFunc(func(_ context.Context, event *RequestEvent) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, *event)
+		})
+		stream := newTestStream(t, NewReflector(
+			&staticNames{names: []string{actualServiceName}},
+			WithObserver(observer),
+			option,
+		))
+		return stream, func() []RequestEvent {
+			mu.Lock()
+			defer mu.Unlock()
+			return events
+		}
+	}
+	checkExhausted := func(t *testing.T, event RequestEvent) {
+		t.Helper()
+		if event.Outcome != OutcomeError || connect.CodeOf(event.Err) != connect.CodeResourceExhausted {
+			t.Errorf("expected resource exhausted, got %v (%v)", event.Outcome, event.Err)
+		}
+		if event.Files != 0 || event.Bytes != 0 {
+			t.Errorf("expected no files to be sent, got %d files totaling %d bytes", event.Files, event.Bytes)
+		}
+	}
+
+	t.Run("max_bytes", func(t *testing.T) {
+		t.Parallel()
+		stream, getEvents := newStream(t, WithMaxBytesPerStream(10))
+		if _, err := stream.FileContainingSymbol(actualServiceName); connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		events := getEvents()
+		if len(events) != 1 {
+			t.Fatalf("expected 1 event, got %v", events)
+		}
+		if events[0].Type != RequestTypeFileContainingSymbol || events[0].Argument != actualServiceName {
+			t.Errorf("unexpected event: %+v", events[0])
+		}
+		checkExhausted(t, events[0])
+	})
+	t.Run("max_requests", func(t *testing.T) {
+		t.Parallel()
+		stream, getEvents := newStream(t, WithMaxRequestsPerStream(1))
+		if _, err := stream.ListServices(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := stream.ListServices(); connect.CodeOf(err) != connect.CodeResourceExhausted {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		events := getEvents()
+		if len(events) != 2 {
+			t.Fatalf("expected 2 events, got %v", events)
+		}
+		if events[0].Outcome != OutcomeOK || events[1].Type != RequestTypeListServices {
+			t.Errorf("unexpected events: %+v", events)
+		}
+		checkExhausted(t, events[1])
+	})
+}
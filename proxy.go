@@ -0,0 +1,295 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// NewProxyReflector constructs a Reflector that answers reflection requests by
+// forwarding them to an upstream server. This is useful in gateways and other
+// proxies, which often don't have the schemas of the backends they front
+// compiled in.
+//
+// The list of services, the descriptors, and the extensions all come from the
+// upstream server. Descriptors are downloaded lazily and cached using a
+// [ClientResolver], which shares a single long-lived reflection stream across all
+// incoming requests. If that stream breaks, the proxy opens a new one (with an
+// empty cache) and retries the operation once.
+//
+// So that redeployed backends don't leave the proxy with stale descriptors,
+// the proxy also opens a new stream (and starts a new cache) when the upstream
+// server's list of services changes, and at least every five minutes.
+//
+// Each upstream operation waits no longer than the incoming request's context
+// allows. If the upstream server doesn't answer a request within 30 seconds,
+// the proxy assumes the stream is stuck and replaces it.
+//
+// Any supplied options are applied after the proxy's own resolvers are
+// configured, so they may override them.
+func NewProxyReflector(client *Client, options ...Option) *Reflector {
+	upstream := &upstreamResolver{
+		client:  client,
+		timeout: defaultUpstreamTimeout,
+		maxAge:  defaultUpstreamMaxAge,
+	}
+	proxyOptions := []Option{
+		WithDescriptorResolver(upstream),
+		WithExtensionResolver(upstream),
+	}
+	return NewReflector(upstream, append(proxyOptions, options...)...)
+}
+
+const (
+	defaultUpstreamTimeout = 30 * time.Second
+	defaultUpstreamMaxAge  = 5 * time.Minute
+)
+
+// upstreamResolver implements Namer, protodesc.Resolver, and ExtensionResolver
+// (along with their context-aware counterparts) by delegating to a
+// ClientResolver, replacing it whenever its stream breaks or may be stale.
+type upstreamResolver struct {
+	client  *Client
+	timeout time.Duration // for each round trip
+	maxAge  time.Duration // of each stream
+
+	mu       sync.Mutex
+	upstream *upstreamStream
+	names    []string // sorted, as last listed by the upstream server
+}
+
+// upstreamStream is a stream to the upstream server, along with the
+// ClientResolver that caches the descriptors downloaded on it.
+type upstreamStream struct {
+	stream   *ClientStream
+	resolver *ClientResolver
+	cancel   context.CancelFunc
+	opened   time.Time
+
+	// active counts the operations using the stream, and retired is set once
+	// it's been replaced. The stream is closed when both are true. They're
+	// guarded by the upstreamResolver's mutex.
+	active  int
+	retired bool
+}
+
+func (u *upstreamResolver) Names() []string {
+	names, _ := u.NamesContext(context.Background())
+	return names
+}
+
+// NamesContext lists the upstream server's services. Unlike Names, it reports
+// failures, so clients can tell an unavailable upstream from one without any
+// services.
+func (u *upstreamResolver) NamesContext(ctx context.Context) ([]string, error) {
+	return withUpstream(ctx, u, func(upstream *upstreamStream) ([]string, error) {
+		names, err := upstream.stream.ListServices()
+		if err != nil {
+			return nil, err
+		}
+		strs := make([]string, len(names))
+		for i, name := range names {
+			strs[i] = string(name)
+		}
+		u.checkNames(upstream, strs)
+		return strs, nil
+	})
+}
+
+func (u *upstreamResolver) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return u.FindFileByPathContext(context.Background(), path)
+}
+
+func (u *upstreamResolver) FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error) {
+	return withUpstream(ctx, u, func(upstream *upstreamStream) (protoreflect.FileDescriptor, error) {
+		return upstream.resolver.FindFileByPath(path)
+	})
+}
+
+func (u *upstreamResolver) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return u.FindDescriptorByNameContext(context.Background(), name)
+}
+
+func (u *upstreamResolver) FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return withUpstream(ctx, u, func(upstream *upstreamStream) (protoreflect.Descriptor, error) {
+		return upstream.resolver.FindDescriptorByName(name)
+	})
+}
+
+func (u *upstreamResolver) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return withUpstream(context.Background(), u, func(upstream *upstreamStream) (protoreflect.ExtensionType, error) {
+		return upstream.resolver.FindExtensionByName(field)
+	})
+}
+
+func (u *upstreamResolver) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return u.FindExtensionByNumberContext(context.Background(), message, field)
+}
+
+func (u *upstreamResolver) FindExtensionByNumberContext(ctx context.Context, message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return withUpstream(ctx, u, func(upstream *upstreamStream) (protoreflect.ExtensionType, error) {
+		return upstream.resolver.FindExtensionByNumber(message, field)
+	})
+}
+
+func (u *upstreamResolver) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	u.RangeExtensionsByMessageContext(context.Background(), message, f)
+}
+
+func (u *upstreamResolver) RangeExtensionsByMessageContext(ctx context.Context, message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	// ClientResolver.RangeExtensionsByMessage swallows errors, so we list the
+	// extension numbers ourselves to notice broken streams.
+	numbers, err := withUpstream(ctx, u, func(upstream *upstreamStream) ([]protoreflect.FieldNumber, error) {
+		return upstream.stream.AllExtensionNumbers(message)
+	})
+	if err != nil {
+		return
+	}
+	for _, number := range numbers {
+		ext, err := u.FindExtensionByNumberContext(ctx, message, number)
+		if err != nil {
+			continue
+		}
+		if !f(ext) {
+			return
+		}
+	}
+}
+
+// acquire returns the current upstream stream, opening a new one if there
+// isn't one or it's too old. Callers must release the stream when they're done
+// with it.
+func (u *upstreamResolver) acquire() *upstreamStream {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.upstream != nil && u.maxAge > 0 && time.Since(u.upstream.opened) > u.maxAge {
+		u.retireLocked(u.upstream)
+	}
+	if u.upstream == nil {
+		// The stream is shared by all incoming requests, so it can't be tied to
+		// any one of their contexts. Canceling its own context unblocks
+		// operations stuck on it.
+		ctx, cancel := context.WithCancel(context.Background())
+		stream := u.client.NewStream(ctx)
+		stream.roundTripTimeout = u.timeout
+		stream.cancel = cancel
+		u.upstream = &upstreamStream{
+			stream:   stream,
+			resolver: NewClientResolver(stream),
+			cancel:   cancel,
+			opened:   time.Now(),
+		}
+	}
+	u.upstream.active++
+	return u.upstream
+}
+
+// release records that an operation is done with the stream.
+func (u *upstreamResolver) release(upstream *upstreamStream) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	upstream.active--
+	upstream.closeIfUnused()
+}
+
+// retire replaces the given stream, if it's still the current one, so that the
+// next operation opens a new stream. The stream is closed once the operations
+// using it are done.
+func (u *upstreamResolver) retire(upstream *upstreamStream) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.retireLocked(upstream)
+}
+
+func (u *upstreamResolver) retireLocked(upstream *upstreamStream) {
+	if u.upstream == upstream {
+		u.upstream = nil
+	}
+	upstream.retired = true
+	upstream.closeIfUnused()
+}
+
+// checkNames retires the stream the names were listed on if they're different
+// from the names listed before, since the upstream server's schema may have
+// changed along with them.
+func (u *upstreamResolver) checkNames(upstream *upstreamStream, names []string) {
+	names = slices.Clone(names)
+	slices.Sort(names)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.names != nil && !slices.Equal(u.names, names) {
+		u.retireLocked(upstream)
+	}
+	u.names = names
+}
+
+// closeIfUnused closes the stream if it's been retired and no operations are
+// using it. The upstreamResolver's mutex must be held.
+func (s *upstreamStream) closeIfUnused() {
+	if !s.retired || s.active > 0 {
+		return
+	}
+	s.cancel()
+	// The context is canceled, so Close doesn't wait for the server.
+	go func() {
+		_, _ = s.stream.Close()
+	}()
+}
+
+// withUpstream runs op against the current upstream stream. If the stream is
+// broken, it's replaced and op is retried once on the new stream.
+func withUpstream[T any](ctx context.Context, u *upstreamResolver, op func(*upstreamStream) (T, error)) (T, error) {
+	var result T
+	var err error
+	for range 2 {
+		upstream := u.acquire()
+		result, err = callUpstream(ctx, func() (T, error) {
+			defer u.release(upstream)
+			return op(upstream)
+		})
+		if !IsReflectionStreamBroken(err) {
+			return result, err
+		}
+		u.retire(upstream)
+	}
+	return result, err
+}
+
+// callUpstream runs op in its own goroutine, so that the caller stops waiting
+// when its context is done. The operation itself continues until it's done or
+// the stream breaks.
+func callUpstream[T any](ctx context.Context, op func() (T, error)) (T, error) {
+	type outcome struct {
+		result T
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := op()
+		done <- outcome{result: result, err: err}
+	}()
+	select {
+	case out := <-done:
+		return out.result, out.err
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+)
+
+func TestProxyReflector(t *testing.T) {
+	t.Parallel()
+	upstream := newTestServer(t, func(mux *http.ServeMux) {
+		mux.Handle(NewHandlerV1(NewStaticReflector(actualServiceName)))
+	})
+	// The proxies' upstream streams stay open until the connection goes away,
+	// and Close waits for outstanding requests, so we drop connections first.
+	t.Cleanup(upstream.CloseClientConnections)
+
+	t.Run("client", func(t *testing.T) {
+		t.Parallel()
+		upstreamClient := NewClient(upstream.Client(), upstream.URL, connect.WithGRPC())
+		testClient(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(NewProxyReflector(upstreamClient)))
+		})
+	})
+	t.Run("reconnect", func(t *testing.T) {
+		t.Parallel()
+		upstreamClient := NewClient(upstream.Client(), upstream.URL, connect.WithGRPC())
+		upstreamResolver := &upstreamResolver{client: upstreamClient, timeout: defaultUpstreamTimeout}
+		if names := upstreamResolver.Names(); len(names) != 1 || names[0] != actualServiceName {
+			t.Fatalf("unexpected names: %v", names)
+		}
+		stream := upstreamResolver.currentStream()
+		// Break the stream out from under the proxy.
+		_ = stream.getStream().CloseRequest()
+		if _, err := upstreamResolver.FindDescriptorByName(actualServiceName); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if upstreamResolver.currentStream() == stream {
+			t.Fatal("expected proxy to open a new upstream stream")
+		}
+	})
+
+	t.Run("stalled_upstream", func(t *testing.T) {
+		t.Parallel()
+		// The stalled server accepts streams but never answers.
+		stalled := newTestServer(t, func(mux *http.ServeMux) {
+			mux.HandleFunc("/", func(_ http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+			})
+		})
+		t.Cleanup(stalled.CloseClientConnections)
+		stalledClient := NewClient(stalled.Client(), stalled.URL, connect.WithGRPC())
+
+		patient := &upstreamResolver{client: stalledClient, timeout: time.Hour}
+		ctx, cancel := context.WithTimeout(t.Context(), 50*time.Millisecond)
+		defer cancel()
+		if _, err := patient.NamesContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("expected deadline exceeded, got %v", err)
+		}
+
+		impatient := &upstreamResolver{client: stalledClient, timeout: 50 * time.Millisecond}
+		stream := impatient.currentStream()
+		if _, err := impatient.NamesContext(t.Context()); !IsReflectionStreamBroken(err) {
+			t.Fatalf("expected broken stream, got %v", err)
+		}
+		if impatient.currentStream() == stream {
+			t.Fatal("expected proxy to replace the stalled upstream stream")
+		}
+	})
+	t.Run("slow_upstream", func(t *testing.T) {
+		t.Parallel()
+		// Each response takes a while, but the upstream server isn't stuck.
+		slow := newTestServer(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(
+				NewStaticReflector(actualServiceName),
+				connect.WithInterceptors(&slowSendInterceptor{delay: 40 * time.Millisecond}),
+			))
+		})
+		t.Cleanup(slow.CloseClientConnections)
+		slowClient := NewClient(slow.Client(), slow.URL, connect.WithGRPC())
+		resolver := &upstreamResolver{client: slowClient, timeout: 100 * time.Millisecond}
+		upstream := resolver.acquire()
+		defer resolver.release(upstream)
+		// The timeout applies to each round trip, not to all of them.
+		for range 5 {
+			if _, err := upstream.stream.ListServices(); err != nil {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		}
+	})
+	t.Run("service_change", func(t *testing.T) {
+		t.Parallel()
+		namer := &mutableNames{names: []string{actualServiceName}}
+		changing := newTestServer(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(NewReflector(namer)))
+		})
+		t.Cleanup(changing.CloseClientConnections)
+		changingClient := NewClient(changing.Client(), changing.URL, connect.WithGRPC())
+		resolver := &upstreamResolver{client: changingClient, timeout: defaultUpstreamTimeout}
+		resolver.Names()
+		stream := resolver.currentStream()
+		resolver.Names()
+		if resolver.currentStream() != stream {
+			t.Fatal("expected proxy to keep the upstream stream")
+		}
+		namer.set(actualServiceName, "connect.reflecttest.v1.TestService")
+		if names := resolver.Names(); len(names) != 2 {
+			t.Fatalf("unexpected names: %v", names)
+		}
+		if resolver.currentStream() == stream {
+			t.Fatal("expected proxy to open a new upstream stream after the services changed")
+		}
+	})
+	t.Run("max_age", func(t *testing.T) {
+		t.Parallel()
+		upstreamClient := NewClient(upstream.Client(), upstream.URL, connect.WithGRPC())
+		resolver := &upstreamResolver{client: upstreamClient, timeout: defaultUpstreamTimeout, maxAge: time.Millisecond}
+		resolver.Names()
+		stream := resolver.currentStream()
+		time.Sleep(2 * time.Millisecond)
+		if resolver.currentStream() == stream {
+			t.Fatal("expected proxy to open a new upstream stream after the old one expired")
+		}
+	})
+}
+
+// currentStream returns the stream the resolver uses for its next operation.
+func (u *upstreamResolver) currentStream() *ClientStream {
+	upstream := u.acquire()
+	u.release(upstream)
+	return upstream.stream
+}
+
+// mutableNames is a Namer whose names can be changed.
+type mutableNames struct {
+	mu    sync.Mutex
+	names []string
+}
+
+func (n *mutableNames) Names() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.names
+}
+
+func (n *mutableNames) set(names ...string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.names = names
+}
+
+// slowSendInterceptor delays every message sent by streaming handlers.
+type slowSendInterceptor struct {
+	delay time.Duration
+}
+
+func (i *slowSendInterceptor) WrapUnary(next connect.UnaryFunc) connect.UnaryFunc {
+	return next
+}
+
+func (i *slowSendInterceptor) WrapStreamingClient(next connect.StreamingClientFunc) connect.StreamingClientFunc {
+	return next
+}
+
+func (i *slowSendInterceptor) WrapStreamingHandler(next connect.StreamingHandlerFunc) connect.StreamingHandlerFunc {
+	return func(ctx context.Context, conn connect.StreamingHandlerConn) error {
+		return next(ctx, &slowSendConn{StreamingHandlerConn: conn, delay: i.delay})
+	}
+}
+
+type slowSendConn struct {
+	connect.StreamingHandlerConn
+
+	delay time.Duration
+}
+
+func (c *slowSendConn) Send(msg any) error {
+	time.Sleep(c.delay)
+	return c.StreamingHandlerConn.Send(msg)
+}
@@ -0,0 +1,108 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// A Mux registers HTTP handlers. *http.ServeMux implements Mux, as do most
+// third-party routers.
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// HandlerRegistry mounts Connect handlers on a Mux and keeps track of the
+// services they implement, so that the list of services available for
+// reflection can't drift from the services that are actually mounted.
+// HandlerRegistry implements Namer, and it's safe to mount handlers while
+// serving reflection requests.
+//
+// Use it in place of calls to the Mux's Handle method:
+//
+//	mux := http.NewServeMux()
+//	registry := grpcreflect.NewHandlerRegistry(mux)
+//	registry.Handle(userv1connect.NewUserServiceHandler(userService))
+//	registry.Handle(grpcreflect.NewHandlerV1(grpcreflect.NewReflector(registry)))
+type HandlerRegistry struct {
+	mux Mux
+
+	mu    sync.RWMutex
+	names []string
+	seen  map[string]struct{}
+}
+
+// NewHandlerRegistry constructs a HandlerRegistry that mounts handlers on the
+// given Mux.
+func NewHandlerRegistry(mux Mux) *HandlerRegistry {
+	return &HandlerRegistry{
+		mux:  mux,
+		seen: make(map[string]struct{}),
+	}
+}
+
+// Handle mounts the handler on the Mux, and records the fully-qualified name
+// of the service implemented by the handler. Its arguments match the path and
+// handler returned by generated NewXServiceHandler functions, which are
+// mounted on a path like "/acme.user.v1.UserService/". The service name is the
+// last element of the path, so prefixes like "/api/acme.user.v1.UserService/"
+// work too. Paths that don't end with a package-qualified service name and a
+// slash are still mounted, but they aren't listed for reflection.
+func (r *HandlerRegistry) Handle(path string, handler http.Handler) {
+	r.mux.Handle(path, handler)
+	name, ok := serviceNameFromPath(path)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.seen[name]; ok {
+		return
+	}
+	r.seen[name] = struct{}{}
+	r.names = append(r.names, name)
+}
+
+// Names returns the names of the services mounted so far, in the order they
+// were mounted, implements the Namer interface.
+func (r *HandlerRegistry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
+
+// serviceNameFromPath extracts the fully-qualified service name from the path
+// on which a Connect handler is mounted. Handler paths always end with a slash,
+// and we require a package so that paths like "/healthz/" aren't mistaken for
+// services.
+func serviceNameFromPath(path string) (string, bool) {
+	path, ok := strings.CutSuffix(path, "/")
+	if !ok {
+		return "", false
+	}
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		path = path[i+1:]
+	}
+	if !strings.Contains(path, ".") || !protoreflect.FullName(path).IsValid() {
+		return "", false
+	}
+	return path, true
+}
@@ -0,0 +1,74 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestHandlerRegistry(t *testing.T) {
+	t.Parallel()
+	var registry *HandlerRegistry
+	client := newTestClient(t, func(mux *http.ServeMux) {
+		registry = NewHandlerRegistry(mux)
+		registry.Handle(NewHandlerV1(NewReflector(registry)))
+		registry.Handle("/healthz", http.NotFoundHandler())
+	})
+	stream := client.NewStream(t.Context())
+	t.Cleanup(func() {
+		_, _ = stream.Close()
+	})
+
+	names, err := stream.ListServices()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if expected := []protoreflect.FullName{ReflectV1ServiceName}; !reflect.DeepEqual(expected, names) {
+		t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+	}
+
+	// Services mounted later are listed too.
+	registry.Handle("/api/connect.reflecttest.v1.TestService/", http.NotFoundHandler())
+	names, err = stream.ListServices()
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	expected := []protoreflect.FullName{ReflectV1ServiceName, "connect.reflecttest.v1.TestService"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+	}
+}
+
+func TestServiceNameFromPath(t *testing.T) {
+	t.Parallel()
+	for path, expected := range map[string]string{
+		"/acme.user.v1.UserService/":     "acme.user.v1.UserService",
+		"/api/acme.user.v1.UserService/": "acme.user.v1.UserService",
+		"/acme.user.v1.UserService":      "",
+		"/healthz/":                      "",
+		"/healthz/live":                  "",
+		"/":                              "",
+		"/acme..UserService/":            "",
+	} {
+		name, ok := serviceNameFromPath(path)
+		if name != expected || ok != (expected != "") {
+			t.Errorf("%q: want %q, got %q (%v)", path, expected, name, ok)
+		}
+	}
+}
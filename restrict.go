@@ -0,0 +1,104 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// WithServiceFilesOnly restricts the Reflector to the files that define the
+// services listed by its Namer, the transitive imports of those files, and
+// any additional files named by path (along with their transitive imports).
+// Requests for any other file, or for symbols and extensions defined in other
+// files, are answered with a "Not Found" error, exactly as if they didn't exist.
+//
+// This makes it safer to mount reflection on a public API, since the
+// Reflector no longer exposes every type compiled into the binary. The set of
+// allowed files is computed once per reflection stream.
+func WithServiceFilesOnly(additionalFiles ...string) Option {
+	return &serviceFilesOnlyOption{additionalFiles: additionalFiles}
+}
+
+// checkFileAllowed returns an error wrapping protoregistry.NotFound if the
+// Reflector restricts the files it serves and the given file isn't allowed.
+func (r *Reflector) checkFileAllowed(ctx context.Context, file protoreflect.FileDescriptor, state *streamState) error {
+	if !r.restrictFiles {
+		return nil
+	}
+	if state.allowedFiles == nil {
+		allowed, err := r.computeAllowedFiles(ctx)
+		if err != nil {
+			return err
+		}
+		state.allowedFiles = allowed
+	}
+	if _, ok := state.allowedFiles[file.Path()]; !ok {
+		return protoregistry.NotFound
+	}
+	return nil
+}
+
+func (r *Reflector) computeAllowedFiles(ctx context.Context) (map[string]struct{}, error) {
+	names, err := namesContext(ctx, r.namer)
+	if err != nil {
+		return nil, err
+	}
+	var roots []protoreflect.FileDescriptor
+	for _, name := range names {
+		desc, err := findDescriptorByName(ctx, r.descriptorResolver, protoreflect.FullName(name))
+		if errors.Is(err, protoregistry.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		roots = append(roots, desc.ParentFile())
+	}
+	for _, path := range r.extraAllowedFiles {
+		file, err := findFileByPath(ctx, r.descriptorResolver, path)
+		if errors.Is(err, protoregistry.NotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		roots = append(roots, file)
+	}
+	allowed := make(map[string]struct{})
+	for len(roots) > 0 {
+		curr := roots[len(roots)-1]
+		roots = roots[:len(roots)-1]
+		if _, ok := allowed[curr.Path()]; ok || curr.IsPlaceholder() {
+			continue
+		}
+		allowed[curr.Path()] = struct{}{}
+		imports := curr.Imports()
+		for i := range imports.Len() {
+			roots = append(roots, imports.Get(i).FileDescriptor)
+		}
+	}
+	return allowed, nil
+}
+
+type serviceFilesOnlyOption struct {
+	additionalFiles []string
+}
+
+func (o *serviceFilesOnlyOption) apply(reflector *Reflector) {
+	reflector.restrictFiles = true
+	reflector.extraAllowedFiles = o.additionalFiles
+}
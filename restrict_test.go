@@ -0,0 +1,96 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"reflect"
+	"testing"
+
+	"connectrpc.com/connect"
+	_ "connectrpc.com/grpcreflect/internal/gen/go/connect/reflecttest/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestServiceFilesOnly(t *testing.T) {
+	t.Parallel()
+	const (
+		reflecttestPath    = "connect/reflecttest/v1/reflecttest.proto"
+		reflecttestExtPath = "connect/reflecttest/v1/reflecttest_ext.proto"
+		extendableName     = "connect.reflecttest.v1.Extendable"
+	)
+	newStream := func(t *testing.T, additionalFiles ...string) *ClientStream {
+		t.Helper()
+		reflector := NewReflector(
+			&staticNames{names: []string{actualServiceName}},
+			WithServiceFilesOnly(additionalFiles...),
+		)
+		return newTestStream(t, reflector)
+	}
+	expectNotFound := func(t *testing.T, err error) {
+		t.Helper()
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("unexpected code: want %v, got %v (%v)", connect.CodeNotFound, connect.CodeOf(err), err)
+		}
+	}
+	expectExtensions := func(t *testing.T, stream *ClientStream, expected []protoreflect.FieldNumber) {
+		t.Helper()
+		numbers, err := stream.AllExtensionNumbers(extendableName)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if !reflect.DeepEqual(expected, numbers) {
+			t.Fatalf("unexpected extension numbers: want %v ; got %v", expected, numbers)
+		}
+	}
+
+	t.Run("services_only", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t)
+		if _, err := stream.FileContainingSymbol(actualServiceName); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		_, err := stream.FileByFilename(reflecttestPath)
+		expectNotFound(t, err)
+		_, err = stream.FileContainingSymbol("connect.reflecttest.v1.TestService")
+		expectNotFound(t, err)
+		_, err = stream.FileContainingExtension(extendableName, 10)
+		expectNotFound(t, err)
+		_, err = stream.AllExtensionNumbers(extendableName)
+		expectNotFound(t, err)
+	})
+	t.Run("additional_file", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, reflecttestPath)
+		if _, err := stream.FileByFilename(reflecttestPath); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		// The extensions are defined in a file that isn't allowed.
+		expectExtensions(t, stream, []protoreflect.FieldNumber{})
+		_, err := stream.FileContainingExtension(extendableName, 10)
+		expectNotFound(t, err)
+	})
+	t.Run("additional_file_with_imports", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, reflecttestExtPath)
+		files, err := stream.FileContainingExtension(extendableName, 10)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(files) != 2 {
+			t.Fatalf("expected file and its import, got %d files", len(files))
+		}
+		expectExtensions(t, stream, []protoreflect.FieldNumber{10, 11})
+	})
+}
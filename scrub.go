@@ -0,0 +1,143 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// WithoutSourceCodeInfo removes source code info from file descriptors before
+// they're sent to clients. Source code info holds comments (including TODOs
+// and other notes that may not be meant for outsiders) along with the line
+// and column of each element.
+//
+// Descriptors compiled into Go binaries don't usually have source code info,
+// but descriptors from other sources often do.
+func WithoutSourceCodeInfo() Option {
+	return &transformOption{transform: func(file *descriptorpb.FileDescriptorProto) {
+		file.SourceCodeInfo = nil
+	}}
+}
+
+// WithoutLanguageOptions removes language-specific code generation options,
+// like go_package and java_package, from file descriptors before they're sent
+// to clients. These options often reveal internal repository paths, and
+// they're of no use to clients that work with descriptors dynamically.
+func WithoutLanguageOptions() Option {
+	return &transformOption{transform: func(file *descriptorpb.FileDescriptorProto) {
+		options := file.GetOptions()
+		if options == nil {
+			return
+		}
+		options.JavaPackage = nil
+		options.JavaOuterClassname = nil
+		options.JavaMultipleFiles = nil
+		options.JavaGenerateEqualsAndHash = nil //nolint:staticcheck // deprecated, but still worth removing
+		options.JavaStringCheckUtf8 = nil
+		options.OptimizeFor = nil
+		options.GoPackage = nil
+		options.CcGenericServices = nil
+		options.JavaGenericServices = nil
+		options.PyGenericServices = nil
+		options.CcEnableArenas = nil
+		options.ObjcClassPrefix = nil
+		options.CsharpNamespace = nil
+		options.SwiftPrefix = nil
+		options.PhpClassPrefix = nil
+		options.PhpNamespace = nil
+		options.PhpMetadataNamespace = nil
+		options.RubyPackage = nil
+		if proto.Size(options) == 0 {
+			file.Options = nil
+		}
+	}}
+}
+
+// WithoutCustomOptions removes the given custom options from file descriptors
+// before they're sent to clients. Custom options are extensions of the
+// options messages in google/protobuf/descriptor.proto, and they're removed
+// from the options of every element in a file: the file itself, messages,
+// fields, enums, services, methods, and so on.
+//
+// Generated Go code exports a variable for each extension (usually prefixed
+// with "E_"), which implements protoreflect.ExtensionType. For extensions that
+// aren't compiled into the binary, use dynamicpb.NewExtensionType.
+func WithoutCustomOptions(extensions ...protoreflect.ExtensionType) Option {
+	return &transformOption{transform: func(file *descriptorpb.FileDescriptorProto) {
+		rangeOptions(file.ProtoReflect(), func(options protoreflect.Message) {
+			for _, ext := range extensions {
+				desc := ext.TypeDescriptor()
+				if desc.ContainingMessage().FullName() != options.Descriptor().FullName() {
+					continue
+				}
+				// Depending on whether the extension was known when the options
+				// were unmarshaled, it may be a known or an unknown field.
+				options.Clear(desc)
+				clearUnknownField(options, desc.Number())
+			}
+		})
+	}}
+}
+
+// rangeOptions calls f with every options message in the given descriptor,
+// no matter how deeply it's nested.
+func rangeOptions(msg protoreflect.Message, f func(protoreflect.Message)) {
+	msg.Range(func(field protoreflect.FieldDescriptor, value protoreflect.Value) bool {
+		if field.Message() == nil || field.IsExtension() {
+			return true
+		}
+		if field.Name() == "options" {
+			f(value.Message())
+			return true
+		}
+		if field.IsList() {
+			list := value.List()
+			for i := range list.Len() {
+				rangeOptions(list.Get(i).Message(), f)
+			}
+			return true
+		}
+		rangeOptions(value.Message(), f)
+		return true
+	})
+}
+
+func clearUnknownField(msg protoreflect.Message, number protoreflect.FieldNumber) {
+	unknown := msg.GetUnknown()
+	var kept protoreflect.RawFields
+	for len(unknown) > 0 {
+		num, _, length := protowire.ConsumeField(unknown)
+		if length < 0 {
+			// Malformed, so leave everything as it was.
+			return
+		}
+		if num != number {
+			kept = append(kept, unknown[:length]...)
+		}
+		unknown = unknown[length:]
+	}
+	msg.SetUnknown(kept)
+}
+
+type transformOption struct {
+	transform func(*descriptorpb.FileDescriptorProto)
+}
+
+func (o *transformOption) apply(reflector *Reflector) {
+	reflector.transforms = append(reflector.transforms, o.transform)
+}
@@ -0,0 +1,156 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestScrubbing(t *testing.T) {
+	t.Parallel()
+	optionsProto := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("custom/options.proto"),
+		Package:    proto.String("custom"),
+		Dependency: []string{"google/protobuf/descriptor.proto"},
+		Extension: []*descriptorpb.FieldDescriptorProto{
+			{
+				Name:     proto.String("internal"),
+				Number:   proto.Int32(50000),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_BOOL.Enum(),
+				Extendee: proto.String(".google.protobuf.MessageOptions"),
+			},
+			{
+				Name:     proto.String("owner"),
+				Number:   proto.Int32(50001),
+				Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+				Type:     descriptorpb.FieldDescriptorProto_TYPE_STRING.Enum(),
+				Extendee: proto.String(".google.protobuf.FileOptions"),
+			},
+		},
+	}
+	optionsFile, err := protodesc.NewFile(optionsProto, globalFiles)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	internalExt := dynamicpb.NewExtensionType(optionsFile.Extensions().ByName("internal"))
+	ownerExt := dynamicpb.NewExtensionType(optionsFile.Extensions().ByName("owner"))
+
+	// The "internal" option is a known field, but the "owner" option (and an
+	// option we don't scrub) are unknown fields, as they would be if the
+	// extensions weren't linked into the binary.
+	messageOptions := &descriptorpb.MessageOptions{}
+	proto.SetExtension(messageOptions, internalExt, true)
+	fileOptions := &descriptorpb.FileOptions{
+		GoPackage:   proto.String("example.com/internal/repo/gen/thingv1"),
+		JavaPackage: proto.String("com.example.internal.thing.v1"),
+		Deprecated:  proto.Bool(true),
+	}
+	var unknown []byte
+	unknown = protowire.AppendTag(unknown, 50001, protowire.BytesType)
+	unknown = protowire.AppendString(unknown, "team-secret")
+	unknown = protowire.AppendTag(unknown, 50002, protowire.VarintType)
+	unknown = protowire.AppendVarint(unknown, 1)
+	fileOptions.ProtoReflect().SetUnknown(unknown)
+	thingProto := &descriptorpb.FileDescriptorProto{
+		Name:       proto.String("thing.proto"),
+		Package:    proto.String("thing.v1"),
+		Dependency: []string{"custom/options.proto"},
+		Options:    fileOptions,
+		MessageType: []*descriptorpb.DescriptorProto{{
+			Name:    proto.String("Thing"),
+			Options: messageOptions,
+		}},
+		SourceCodeInfo: &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{{
+				Path:            []int32{4, 0},
+				Span:            []int32{1, 0, 10},
+				LeadingComments: proto.String(" TODO: remove before launch\n"),
+			}},
+		},
+	}
+	optionsFiles := &protoregistry.Files{}
+	if err := optionsFiles.RegisterFile(optionsFile); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resolver := &combinedResolver{first: globalFiles, second: optionsFiles}
+	thingFile, err := protodesc.NewFile(thingProto, resolver)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	encode := func(t *testing.T, options ...Option) *descriptorpb.FileDescriptorProto {
+		t.Helper()
+		data, err := NewReflector(&staticNames{}, options...).encodeFile(thingFile)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		fileProto := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, fileProto); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		return fileProto
+	}
+
+	t.Run("no_scrubbing", func(t *testing.T) {
+		t.Parallel()
+		fileProto := encode(t)
+		if fileProto.GetSourceCodeInfo() == nil {
+			t.Fatal("expected source code info")
+		}
+		if fileProto.GetOptions().GetGoPackage() == "" {
+			t.Fatal("expected go_package")
+		}
+	})
+	t.Run("source_code_info", func(t *testing.T) {
+		t.Parallel()
+		fileProto := encode(t, WithoutSourceCodeInfo())
+		if fileProto.GetSourceCodeInfo() != nil {
+			t.Fatal("expected source code info to be removed")
+		}
+	})
+	t.Run("language_options", func(t *testing.T) {
+		t.Parallel()
+		fileProto := encode(t, WithoutLanguageOptions())
+		options := fileProto.GetOptions()
+		if options.GoPackage != nil || options.JavaPackage != nil {
+			t.Fatalf("expected language options to be removed: %v", options)
+		}
+		if !options.GetDeprecated() {
+			t.Fatal("expected other options to be preserved")
+		}
+	})
+	t.Run("custom_options", func(t *testing.T) {
+		t.Parallel()
+		fileProto := encode(t, WithoutCustomOptions(internalExt, ownerExt))
+		messageOptions := fileProto.GetMessageType()[0].GetOptions()
+		if len(messageOptions.ProtoReflect().GetUnknown()) != 0 || proto.Size(messageOptions) != 0 {
+			t.Fatalf("expected message options to be empty: %v", messageOptions)
+		}
+		var expectUnknown []byte
+		expectUnknown = protowire.AppendTag(expectUnknown, 50002, protowire.VarintType)
+		expectUnknown = protowire.AppendVarint(expectUnknown, 1)
+		if unknown := fileProto.GetOptions().ProtoReflect().GetUnknown(); string(unknown) != string(expectUnknown) {
+			t.Fatalf("expected only the unscrubbed option to remain, got %x", unknown)
+		}
+	})
+}
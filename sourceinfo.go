@@ -0,0 +1,140 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// WithSourceInfo attaches source code info (comments, along with the
+// location of each element) from the given descriptor set to the files the
+// Reflector sends, so that clients like grpcurl can show documentation.
+// Descriptors compiled into Go binaries don't include source code info, but
+// descriptor sets built with "buf build --as-file-descriptor-set" or "protoc
+// --include_source_info --descriptor_set_out" do.
+//
+// Files are matched by path. Source code info refers to elements by their
+// position in the file, so it's only attached if the file in the set has the
+// same structure as the file being sent: apart from source code info and file
+// options (which code generators may add), the two files must be identical.
+// Files that don't match are sent without source code info. WithSourceInfo
+// may be used more than once; if several sets contain the same file, the last
+// one wins.
+//
+// The Reflector keeps a reference to the descriptor set, which must not be
+// modified afterwards. To remove the source info again for some clients, use
+// WithoutSourceCodeInfo.
+func WithSourceInfo(fileSet *descriptorpb.FileDescriptorSet) Option {
+	files := make(map[string]*sourceInfoFile)
+	for _, fileProto := range fileSet.GetFile() {
+		if fileProto.GetSourceCodeInfo() == nil {
+			continue
+		}
+		files[fileProto.GetName()] = &sourceInfoFile{
+			normalized: normalizeForSourceInfo(fileProto),
+			info:       fileProto.GetSourceCodeInfo(),
+		}
+	}
+	return &sourceInfoOption{files: files}
+}
+
+type sourceInfoFile struct {
+	// normalized is the file without its source code info, normalized for
+	// comparison.
+	normalized *descriptorpb.FileDescriptorProto
+	info       *descriptorpb.SourceCodeInfo
+}
+
+// attachSourceInfo copies source code info into the file, if the Reflector
+// has matching source info for it.
+func (r *Reflector) attachSourceInfo(fileProto *descriptorpb.FileDescriptorProto) {
+	source, ok := r.sourceInfo[fileProto.GetName()]
+	if !ok || !proto.Equal(source.normalized, normalizeForSourceInfo(fileProto)) {
+		return
+	}
+	fileProto.SourceCodeInfo, _ = proto.Clone(source.info).(*descriptorpb.SourceCodeInfo)
+}
+
+// normalizeForSourceInfo returns a copy of the file without source code info
+// or file options, and with the optional parts of the descriptor that
+// compilers and protodesc.ToFileDescriptorProto fill in differently filled in
+// consistently.
+func normalizeForSourceInfo(fileProto *descriptorpb.FileDescriptorProto) *descriptorpb.FileDescriptorProto {
+	normalized, _ := proto.Clone(fileProto).(*descriptorpb.FileDescriptorProto)
+	normalized.SourceCodeInfo = nil
+	// Code generators like buf's managed mode add file options, like
+	// go_package, that aren't in the source files. They don't change the
+	// structure of the file, so we ignore them.
+	normalized.Options = nil
+	if normalized.GetSyntax() == "proto2" {
+		normalized.Syntax = nil
+	}
+	normalizeFields(normalized.GetExtension())
+	for _, message := range normalized.GetMessageType() {
+		normalizeMessage(message)
+	}
+	return normalized
+}
+
+func normalizeMessage(message *descriptorpb.DescriptorProto) {
+	normalizeFields(message.GetField())
+	normalizeFields(message.GetExtension())
+	for _, nested := range message.GetNestedType() {
+		normalizeMessage(nested)
+	}
+}
+
+// normalizeFields sets the JSON name of every field, since it's optional in
+// descriptors.
+func normalizeFields(fields []*descriptorpb.FieldDescriptorProto) {
+	for _, field := range fields {
+		if field.JsonName == nil {
+			field.JsonName = proto.String(jsonCamelCase(field.GetName()))
+		}
+	}
+}
+
+// jsonCamelCase returns the default JSON name for a field, following the same
+// rules as protoc: underscores are removed, and any lowercase letter that
+// follows one is capitalized.
+func jsonCamelCase(name string) string {
+	var camel []byte
+	afterUnderscore := false
+	for i := range len(name) {
+		c := name[i]
+		if c != '_' {
+			if afterUnderscore && 'a' <= c && c <= 'z' {
+				c -= 'a' - 'A'
+			}
+			camel = append(camel, c)
+		}
+		afterUnderscore = c == '_'
+	}
+	return string(camel)
+}
+
+type sourceInfoOption struct {
+	files map[string]*sourceInfoFile
+}
+
+func (o *sourceInfoOption) apply(reflector *Reflector) {
+	if reflector.sourceInfo == nil {
+		reflector.sourceInfo = make(map[string]*sourceInfoFile, len(o.files))
+	}
+	for path, file := range o.files {
+		reflector.sourceInfo[path] = file
+	}
+}
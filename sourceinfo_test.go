@@ -0,0 +1,132 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestSourceInfo(t *testing.T) {
+	t.Parallel()
+	const path = "connect/reflecttest/v1/reflecttest.proto"
+	const comment = " Extendable is extended in tests.\n"
+	file, err := protoregistry.GlobalFiles.FindFileByPath(path)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	// newSourceFile returns the file as a compiler would produce it, with
+	// source code info and without the optional fields that
+	// ToFileDescriptorProto fills in.
+	newSourceFile := func() *descriptorpb.FileDescriptorProto {
+		fileProto := protodesc.ToFileDescriptorProto(file)
+		fileProto.Syntax = proto.String("proto2")
+		for _, message := range fileProto.GetMessageType() {
+			for _, field := range message.GetField() {
+				field.JsonName = nil
+			}
+		}
+		fileProto.SourceCodeInfo = &descriptorpb.SourceCodeInfo{
+			Location: []*descriptorpb.SourceCodeInfo_Location{{
+				Path:            []int32{4, 0},
+				Span:            []int32{18, 0, 21, 1},
+				LeadingComments: proto.String(comment),
+			}},
+		}
+		return fileProto
+	}
+	getSourceInfo := func(t *testing.T, options ...Option) *descriptorpb.SourceCodeInfo {
+		t.Helper()
+		stream := newTestStream(t, NewReflector(&staticNames{}, options...))
+		descriptors, err := stream.FileByFilename(path)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(descriptors) != 1 {
+			t.Fatalf("expected 1 file, got %d", len(descriptors))
+		}
+		return descriptors[0].GetSourceCodeInfo()
+	}
+
+	t.Run("matching", func(t *testing.T) {
+		t.Parallel()
+		fileSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{newSourceFile()}}
+		info := getSourceInfo(t, WithSourceInfo(fileSet))
+		if len(info.GetLocation()) != 1 || info.GetLocation()[0].GetLeadingComments() != comment {
+			t.Fatalf("expected source info to be attached, got %v", info)
+		}
+	})
+	t.Run("managed_options", func(t *testing.T) {
+		t.Parallel()
+		// Descriptor sets built from source don't have the options that
+		// generators add, like go_package.
+		sourceFile := newSourceFile()
+		sourceFile.Options = nil
+		fileSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{sourceFile}}
+		info := getSourceInfo(t, WithSourceInfo(fileSet))
+		if len(info.GetLocation()) != 1 || info.GetLocation()[0].GetLeadingComments() != comment {
+			t.Fatalf("expected source info to be attached, got %v", info)
+		}
+	})
+	t.Run("mismatched", func(t *testing.T) {
+		t.Parallel()
+		sourceFile := newSourceFile()
+		sourceFile.GetMessageType()[0].GetField()[0].Name = proto.String("count")
+		fileSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{sourceFile}}
+		if info := getSourceInfo(t, WithSourceInfo(fileSet)); info != nil {
+			t.Fatalf("expected no source info for a different file, got %v", info)
+		}
+	})
+	t.Run("other_path", func(t *testing.T) {
+		t.Parallel()
+		sourceFile := newSourceFile()
+		sourceFile.Name = proto.String("elsewhere/reflecttest.proto")
+		fileSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{sourceFile}}
+		if info := getSourceInfo(t, WithSourceInfo(fileSet)); info != nil {
+			t.Fatalf("expected no source info for a different path, got %v", info)
+		}
+	})
+	t.Run("scrubbed", func(t *testing.T) {
+		t.Parallel()
+		fileSet := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{newSourceFile()}}
+		if info := getSourceInfo(t, WithSourceInfo(fileSet), WithoutSourceCodeInfo()); info != nil {
+			t.Fatalf("expected WithoutSourceCodeInfo to remove source info, got %v", info)
+		}
+	})
+}
+
+func TestJSONCamelCase(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "number", expected: "number"},
+		{name: "page_size", expected: "pageSize"},
+		{name: "_leading", expected: "Leading"},
+		{name: "double__underscore", expected: "doubleUnderscore"},
+		{name: "digit_1", expected: "digit1"},
+		{name: "already_Upper", expected: "alreadyUpper"},
+	}
+	for _, testCase := range testCases {
+		if actual := jsonCamelCase(testCase.name); actual != testCase.expected {
+			t.Errorf("jsonCamelCase(%q): want %q ; got %q", testCase.name, testCase.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// WithStrictDependencies makes Reflectors refuse to send files that can't be
+// linked because some of their imports, direct or indirect, are missing from
+// the descriptor resolver. Such requests are answered with a "Failed
+// Precondition" error that wraps a *MissingImportsError naming the missing
+// files. By default, Reflectors send what they have and silently skip the
+// missing imports, so clients only find out when they fail to link the files.
+//
+// Like WithFileDependencies, this option is applied by the Reflector used to
+// build the handler. To find missing imports before serving any requests, use
+// Reflector.Validate.
+func WithStrictDependencies() Option {
+	return &strictDependenciesOption{}
+}
+
+// MissingImportsError reports that a file imports other files, directly or
+// indirectly, that can't be found.
+type MissingImportsError struct {
+	// Path is the path of the file that can't be linked.
+	Path string
+	// Imports holds the sorted paths of the missing files.
+	Imports []string
+}
+
+func (e *MissingImportsError) Error() string {
+	quoted := make([]string, len(e.Imports))
+	for i, path := range e.Imports {
+		quoted[i] = fmt.Sprintf("%q", path)
+	}
+	return fmt.Sprintf("%q can't be linked: missing imports %s", e.Path, strings.Join(quoted, ", "))
+}
+
+// Validate checks that the Reflector can describe every service listed by its
+// Namer: that each service can be found, and that none of the files needed to
+// link its definition are missing. It's intended to be called at startup, so
+// that misconfigured schemas are reported before any clients run into them.
+// The context is passed to context-aware Namers and resolvers.
+//
+// Validate returns nil if there are no problems. Otherwise, it returns an error
+// that joins all the problems it found, including a *MissingImportsError for
+// each file with missing imports.
+func (r *Reflector) Validate(ctx context.Context) error {
+	names, err := namesContext(ctx, r.namer)
+	if err != nil {
+		return fmt.Errorf("list services: %w", err)
+	}
+	var errs []error
+	checked := make(map[string]struct{})
+	for _, name := range names {
+		desc, err := findDescriptorByName(ctx, r.descriptorResolver, protoreflect.FullName(name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("service %s: %w", name, err))
+			continue
+		}
+		if _, ok := desc.(protoreflect.ServiceDescriptor); !ok {
+			errs = append(errs, fmt.Errorf("service %s: found %s instead", name, describeKind(desc)))
+			continue
+		}
+		file := desc.ParentFile()
+		if _, ok := checked[file.Path()]; ok {
+			continue
+		}
+		checked[file.Path()] = struct{}{}
+		if err := checkMissingImports(file); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkMissingImports returns a *MissingImportsError if any of the file's
+// transitive imports are placeholders.
+func checkMissingImports(root protoreflect.FileDescriptor) *MissingImportsError {
+	var missing []string
+	visited := map[string]struct{}{root.Path(): {}}
+	queue := []protoreflect.FileDescriptor{root}
+	for len(queue) > 0 {
+		imports := queue[0].Imports()
+		queue = queue[1:]
+		for i := range imports.Len() {
+			imported := imports.Get(i).FileDescriptor
+			if _, ok := visited[imported.Path()]; ok {
+				continue
+			}
+			visited[imported.Path()] = struct{}{}
+			if imported.IsPlaceholder() {
+				missing = append(missing, imported.Path())
+				continue
+			}
+			queue = append(queue, imported)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return &MissingImportsError{Path: root.Path(), Imports: missing}
+}
+
+// newMissingImportsError wraps the error in the code sent to clients.
+func newMissingImportsError(err *MissingImportsError) error {
+	return connect.NewError(connect.CodeFailedPrecondition, err)
+}
+
+// describeKind returns a short description of the kind of descriptor, for use
+// in error messages.
+func describeKind(desc protoreflect.Descriptor) string {
+	switch desc.(type) {
+	case protoreflect.MessageDescriptor:
+		return "a message"
+	case protoreflect.EnumDescriptor:
+		return "an enum"
+	case protoreflect.EnumValueDescriptor:
+		return "an enum value"
+	case protoreflect.FieldDescriptor:
+		return "a field"
+	case protoreflect.MethodDescriptor:
+		return "a method"
+	}
+	return "another kind of element"
+}
+
+type strictDependenciesOption struct{}
+
+func (o *strictDependenciesOption) apply(reflector *Reflector) {
+	reflector.strictDependencies = true
+}
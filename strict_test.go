@@ -0,0 +1,152 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestStrictDependencies(t *testing.T) {
+	t.Parallel()
+	files := newFilesWithMissingImports(t)
+	newStream := func(t *testing.T, options ...Option) *ClientStream {
+		t.Helper()
+		options = append([]Option{WithDescriptorResolver(files)}, options...)
+		return newTestStream(t, NewReflector(&staticNames{}, options...))
+	}
+
+	t.Run("lenient", func(t *testing.T) {
+		t.Parallel()
+		descriptors, err := newStream(t).FileByFilename("broken.proto")
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if len(descriptors) != 2 {
+			t.Fatalf("expected broken.proto and mid.proto, got %d files", len(descriptors))
+		}
+	})
+	t.Run("strict", func(t *testing.T) {
+		t.Parallel()
+		stream := newStream(t, WithStrictDependencies())
+		_, err := stream.FileByFilename("broken.proto")
+		if connect.CodeOf(err) != connect.CodeFailedPrecondition {
+			t.Fatalf("expected failed precondition, got %v", err)
+		}
+		expected := `"broken.proto" can't be linked: missing imports "also_gone.proto", "gone.proto"`
+		if !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error to contain %q, got %v", expected, err)
+		}
+		_, err = stream.FileContainingSymbol("strict.Mid")
+		if connect.CodeOf(err) != connect.CodeFailedPrecondition {
+			t.Fatalf("expected failed precondition, got %v", err)
+		}
+		if _, err := stream.FileContainingSymbol("strict.Healthy"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	files := newFilesWithMissingImports(t)
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewReflector(&staticNames{names: []string{"strict.Healthy"}}, WithDescriptorResolver(files))
+		if err := reflector.Validate(context.Background()); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		reflector := NewReflector(
+			&staticNames{names: []string{"strict.Broken", "strict.Healthy", "strict.Gone", "strict.Mid", "strict.AlsoBroken"}},
+			WithDescriptorResolver(files),
+		)
+		err := reflector.Validate(context.Background())
+		if err == nil {
+			t.Fatal("expected error")
+		}
+		var missingErr *MissingImportsError
+		if !errors.As(err, &missingErr) {
+			t.Fatalf("expected MissingImportsError, got %v", err)
+		}
+		if expected := []string{"also_gone.proto", "gone.proto"}; missingErr.Path != "broken.proto" || !reflect.DeepEqual(expected, missingErr.Imports) {
+			t.Fatalf("unexpected missing imports: %+v", missingErr)
+		}
+		if !errors.Is(err, protoregistry.NotFound) {
+			t.Fatalf("expected NotFound for strict.Gone, got %v", err)
+		}
+		// Each file is only reported once.
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != 3 {
+			t.Fatalf("expected 3 problems, got %q", lines)
+		}
+		if !strings.HasPrefix(lines[1], "service strict.Gone: ") {
+			t.Errorf("unexpected problem: %s", lines[1])
+		}
+		if expected := "service strict.Mid: found a message instead"; lines[2] != expected {
+			t.Errorf("unexpected problem: want %q ; got %q", expected, lines[2])
+		}
+	})
+}
+
+// newFilesWithMissingImports returns a registry in which healthy.proto has no
+// imports, and broken.proto imports gone.proto, which is missing, and
+// mid.proto, which imports also_gone.proto, which is also missing.
+func newFilesWithMissingImports(t *testing.T) *protoregistry.Files {
+	t.Helper()
+	newService := func(name string) *descriptorpb.ServiceDescriptorProto {
+		return &descriptorpb.ServiceDescriptorProto{Name: proto.String(name)}
+	}
+	files := &protoregistry.Files{}
+	for _, fileProto := range []*descriptorpb.FileDescriptorProto{
+		{
+			Name:    proto.String("healthy.proto"),
+			Package: proto.String("strict"),
+			Service: []*descriptorpb.ServiceDescriptorProto{newService("Healthy")},
+		},
+		{
+			Name:        proto.String("mid.proto"),
+			Package:     proto.String("strict"),
+			Dependency:  []string{"also_gone.proto"},
+			MessageType: []*descriptorpb.DescriptorProto{{Name: proto.String("Mid")}},
+		},
+		{
+			Name:       proto.String("broken.proto"),
+			Package:    proto.String("strict"),
+			Dependency: []string{"gone.proto", "mid.proto"},
+			Service:    []*descriptorpb.ServiceDescriptorProto{newService("Broken"), newService("AlsoBroken")},
+		},
+	} {
+		file, err := (protodesc.FileOptions{AllowUnresolvable: true}).New(fileProto, files)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := files.RegisterFile(file); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	return files
+}
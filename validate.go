@@ -0,0 +1,102 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"connectrpc.com/connect"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxNameLength is the longest file path or symbol name that the Reflector
+// will look up. Real names are far shorter; anything longer is almost
+// certainly garbage or abuse.
+const maxNameLength = 4096
+
+// WithTolerantStreams keeps reflection streams open when clients send
+// requests that the Reflector doesn't understand, like requests without a
+// message_request or with a kind of request added to the protocol after this
+// package was written. Instead, those requests are answered with an "Invalid
+// Argument" error, and the client can keep using the stream (along with the
+// record of files already sent on it).
+//
+// By default, Reflectors follow the reference implementation and terminate
+// the stream with an "Invalid Argument" error. Either way, requests with
+// malformed file paths, symbol names, or extension numbers are answered with
+// an "Invalid Argument" error and don't terminate the stream.
+func WithTolerantStreams() Option {
+	return &tolerantStreamsOption{}
+}
+
+// validateRequest checks the names and numbers in a request before they're
+// passed to any resolvers. It doesn't complain about unknown kinds of
+// requests, which are handled separately.
+func validateRequest(request *reflectionv1.ServerReflectionRequest) error {
+	var err error
+	switch messageRequest := request.MessageRequest.(type) {
+	case *reflectionv1.ServerReflectionRequest_FileByFilename:
+		err = validateFilePath(messageRequest.FileByFilename)
+	case *reflectionv1.ServerReflectionRequest_FileContainingSymbol:
+		err = validateSymbol(messageRequest.FileContainingSymbol)
+	case *reflectionv1.ServerReflectionRequest_FileContainingExtension:
+		ext := messageRequest.FileContainingExtension
+		err = validateSymbol(ext.GetContainingType())
+		if number := protowire.Number(ext.GetExtensionNumber()); err == nil && !number.IsValid() {
+			err = fmt.Errorf("invalid extension number %d", number)
+		}
+	case *reflectionv1.ServerReflectionRequest_AllExtensionNumbersOfType:
+		err = validateSymbol(messageRequest.AllExtensionNumbersOfType)
+	}
+	if err != nil {
+		return connect.NewError(connect.CodeInvalidArgument, err)
+	}
+	return nil
+}
+
+func validateFilePath(path string) error {
+	switch {
+	case path == "":
+		return errors.New("empty file path")
+	case len(path) > maxNameLength:
+		return fmt.Errorf("file path is %d bytes long, longer than the maximum of %d", len(path), maxNameLength)
+	case !utf8.ValidString(path):
+		return errors.New("file path isn't valid UTF-8")
+	case strings.ContainsFunc(path, func(r rune) bool { return r < ' ' || r == 0x7f }):
+		return fmt.Errorf("file path %q contains control characters", path)
+	}
+	return nil
+}
+
+func validateSymbol(name string) error {
+	if len(name) > maxNameLength {
+		return fmt.Errorf("symbol is %d bytes long, longer than the maximum of %d", len(name), maxNameLength)
+	}
+	if !protoreflect.FullName(name).IsValid() {
+		return fmt.Errorf("invalid symbol %q", name)
+	}
+	return nil
+}
+
+type tolerantStreamsOption struct{}
+
+func (o *tolerantStreamsOption) apply(reflector *Reflector) {
+	reflector.tolerantStreams = true
+}
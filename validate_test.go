@@ -0,0 +1,153 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"connectrpc.com/connect"
+	reflectionv1 "connectrpc.com/grpcreflect/internal/gen/go/connectext/grpc/reflection/v1"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+func TestRequestValidation(t *testing.T) {
+	t.Parallel()
+	stream := newTestStream(t, NewStaticReflector(actualServiceName))
+
+	requests := map[string]func() error{
+		"empty_path": func() error {
+			_, err := stream.FileByFilename("")
+			return err
+		},
+		"control_characters": func() error {
+			_, err := stream.FileByFilename("foo\x00.proto")
+			return err
+		},
+		"long_path": func() error {
+			_, err := stream.FileByFilename(strings.Repeat("a/", maxNameLength) + "foo.proto")
+			return err
+		},
+		"invalid_symbol": func() error {
+			_, err := stream.FileContainingSymbol("acme..Foo")
+			return err
+		},
+		"long_symbol": func() error {
+			_, err := stream.FileContainingSymbol(protoreflect.FullName(strings.Repeat("a.", maxNameLength) + "Foo"))
+			return err
+		},
+		"invalid_extension_type": func() error {
+			_, err := stream.FileContainingExtension("acme Foo", 100)
+			return err
+		},
+		"negative_extension_number": func() error {
+			_, err := stream.FileContainingExtension("acme.Foo", -1)
+			return err
+		},
+		"zero_extension_number": func() error {
+			_, err := stream.FileContainingExtension("acme.Foo", 0)
+			return err
+		},
+		"invalid_extendee": func() error {
+			_, err := stream.AllExtensionNumbers(".acme.Foo")
+			return err
+		},
+	}
+	for name, request := range requests {
+		err := request()
+		if IsReflectionStreamBroken(err) {
+			t.Fatalf("%s: error should not break the stream: %v", name, err)
+		}
+		if connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Errorf("%s: unexpected code: want %v, got %v (%v)", name, connect.CodeInvalidArgument, connect.CodeOf(err), err)
+		}
+	}
+	if _, err := stream.FileContainingSymbol(actualServiceName); err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+}
+
+func TestTolerantStreams(t *testing.T) {
+	t.Parallel()
+	startStream := func(t *testing.T, options ...Option) *connect.BidiStreamForClient[
+		reflectionv1.ServerReflectionRequest,
+		reflectionv1.ServerReflectionResponse,
+	] {
+		t.Helper()
+		server := newTestServer(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(NewReflector(&staticNames{names: []string{actualServiceName}}, options...)))
+		})
+		client := connect.NewClient[
+			reflectionv1.ServerReflectionRequest,
+			reflectionv1.ServerReflectionResponse,
+		](
+			server.Client(),
+			server.URL+serviceURLPathV1+methodName,
+			connect.WithGRPC(),
+		)
+		stream := client.CallBidiStream(t.Context())
+		t.Cleanup(func() {
+			_ = stream.CloseRequest()
+			_ = stream.CloseResponse()
+		})
+		if err := stream.Send(&reflectionv1.ServerReflectionRequest{}); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		return stream
+	}
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+		stream := startStream(t)
+		_, err := stream.Receive()
+		if connect.CodeOf(err) != connect.CodeInvalidArgument {
+			t.Fatalf("expected stream to fail with %v, got %v", connect.CodeInvalidArgument, err)
+		}
+	})
+	t.Run("tolerant", func(t *testing.T) {
+		t.Parallel()
+		stream := startStream(t, WithTolerantStreams())
+		res, err := stream.Receive()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if code := connect.Code(res.GetErrorResponse().GetErrorCode()); code != connect.CodeInvalidArgument {
+			t.Fatalf("unexpected code: want %v, got %v", connect.CodeInvalidArgument, code)
+		}
+		// The stream is still usable.
+		err = stream.Send(&reflectionv1.ServerReflectionRequest{
+			MessageRequest: &reflectionv1.ServerReflectionRequest_ListServices{},
+		})
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		res, err = stream.Receive()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if services := res.GetListServicesResponse().GetService(); len(services) != 1 {
+			t.Fatalf("unexpected services: %v", services)
+		}
+		if err := stream.CloseRequest(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if _, err := stream.Receive(); !errors.Is(err, io.EOF) {
+			t.Fatalf("expected EOF, got %v", err)
+		}
+	})
+}
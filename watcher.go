@@ -0,0 +1,376 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const defaultWatchInterval = 5 * time.Second
+
+// DescriptorSetWatcher serves the schema in descriptor sets on disk, reloading
+// it whenever the files change. It's intended for gateways and proxies whose
+// schema is updated by dropping new descriptor sets on disk, without
+// restarting the process. The files may be in any of the formats accepted by
+// NewReflectorFromDescriptorSet.
+//
+// DescriptorSetWatcher implements Namer, protodesc.Resolver, and
+// ExtensionResolver, along with their context-aware variants. Use its
+// NewReflector method to construct a Reflector that serves its schema.
+//
+// Reloaded files are linked and validated before they're used. If they're
+// invalid, the watcher keeps serving the previous schema and reports the error
+// (see WithWatchErrorHandler). Otherwise, the new schema replaces the old one
+// atomically. Each reflection stream sees the schema that was current when the
+// stream first used the watcher for its whole lifetime, so clients never see
+// a mix of old and new files.
+//
+// To avoid loading partially-written files, replace descriptor sets
+// atomically: write to a temporary file, then rename it.
+type DescriptorSetWatcher struct {
+	path     string
+	interval time.Duration
+	onError  func(error)
+
+	current atomic.Pointer[descriptorSetSchema]
+
+	mu    sync.Mutex // serializes reloads
+	stamp string
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+}
+
+// WatcherOption configures a DescriptorSetWatcher.
+type WatcherOption interface {
+	apply(*DescriptorSetWatcher)
+}
+
+// WithWatchInterval sets how often a DescriptorSetWatcher checks its files for
+// changes. By default, it checks every five seconds. If interval is zero or
+// negative, the watcher doesn't check on its own, and the schema only changes
+// when DescriptorSetWatcher.Reload is called.
+func WithWatchInterval(interval time.Duration) WatcherOption {
+	return &watchIntervalOption{interval: interval}
+}
+
+// WithWatchErrorHandler sets a function to call when a DescriptorSetWatcher
+// fails to reload its files in the background. By default, such errors are
+// ignored, and the watcher keeps serving the previous schema.
+func WithWatchErrorHandler(handler func(error)) WatcherOption {
+	return &watchErrorHandlerOption{handler: handler}
+}
+
+// NewDescriptorSetWatcher constructs a DescriptorSetWatcher for the descriptor
+// set at path. If path is a directory, the watcher merges the descriptor sets
+// in every file in the directory whose name doesn't start with a dot;
+// subdirectories are ignored. Files that appear in more than one set must be
+// identical.
+//
+// The files are loaded before NewDescriptorSetWatcher returns, and it returns
+// an error if they're invalid. Call Close to stop watching for changes.
+func NewDescriptorSetWatcher(path string, options ...WatcherOption) (*DescriptorSetWatcher, error) {
+	watcher := &DescriptorSetWatcher{
+		path:     path,
+		interval: defaultWatchInterval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, option := range options {
+		option.apply(watcher)
+	}
+	if err := watcher.Reload(); err != nil {
+		return nil, err
+	}
+	if watcher.interval > 0 {
+		go watcher.watch()
+	} else {
+		close(watcher.done)
+	}
+	return watcher, nil
+}
+
+// NewReflector constructs a Reflector that lists the services and serves the
+// files and extensions in the watcher's current schema. Any supplied options
+// are applied after the Reflector's resolvers are configured, so they may
+// override them.
+func (w *DescriptorSetWatcher) NewReflector(options ...Option) *Reflector {
+	watcherOptions := []Option{
+		WithDescriptorResolver(w),
+		WithExtensionResolver(w),
+	}
+	return NewReflector(w, append(watcherOptions, options...)...)
+}
+
+// Reload loads the watcher's files immediately, even if they haven't changed.
+// If they're invalid, it returns an error and the watcher keeps serving the
+// previous schema.
+func (w *DescriptorSetWatcher) Reload() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stamp, err := statDescriptorSets(w.path)
+	if err != nil {
+		return err
+	}
+	return w.loadLocked(stamp)
+}
+
+// Close stops watching for changes. The watcher keeps serving the schema it
+// last loaded.
+func (w *DescriptorSetWatcher) Close() {
+	w.stopOnce.Do(func() {
+		close(w.stop)
+	})
+	<-w.done
+}
+
+// Names implements Namer.
+func (w *DescriptorSetWatcher) Names() []string {
+	return w.current.Load().names
+}
+
+// NamesContext implements NamerContext.
+func (w *DescriptorSetWatcher) NamesContext(ctx context.Context) ([]string, error) {
+	return w.snapshot(ctx).names, nil
+}
+
+// FindFileByPath implements protodesc.Resolver.
+func (w *DescriptorSetWatcher) FindFileByPath(path string) (protoreflect.FileDescriptor, error) {
+	return w.current.Load().files.FindFileByPath(path)
+}
+
+// FindDescriptorByName implements protodesc.Resolver.
+func (w *DescriptorSetWatcher) FindDescriptorByName(name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return w.current.Load().files.FindDescriptorByName(name)
+}
+
+// FindFileByPathContext implements DescriptorResolverContext.
+func (w *DescriptorSetWatcher) FindFileByPathContext(ctx context.Context, path string) (protoreflect.FileDescriptor, error) {
+	return w.snapshot(ctx).files.FindFileByPath(path)
+}
+
+// FindDescriptorByNameContext implements DescriptorResolverContext.
+func (w *DescriptorSetWatcher) FindDescriptorByNameContext(ctx context.Context, name protoreflect.FullName) (protoreflect.Descriptor, error) {
+	return w.snapshot(ctx).files.FindDescriptorByName(name)
+}
+
+// FindExtensionByName implements ExtensionResolver.
+func (w *DescriptorSetWatcher) FindExtensionByName(field protoreflect.FullName) (protoreflect.ExtensionType, error) {
+	return w.current.Load().types.FindExtensionByName(field)
+}
+
+// FindExtensionByNumber implements ExtensionResolver.
+func (w *DescriptorSetWatcher) FindExtensionByNumber(message protoreflect.FullName, field protoreflect.FieldNumber) (protoreflect.ExtensionType, error) {
+	return w.current.Load().types.FindExtensionByNumber(message, field)
+}
+
+// RangeExtensionsByMessage implements ExtensionResolver.
+func (w *DescriptorSetWatcher) RangeExtensionsByMessage(message protoreflect.FullName, f func(protoreflect.ExtensionType) bool) {
+	w.current.Load().types.RangeExtensionsByMessage(message, f)
+}
+
+// FindExtensionByNumberContext implements ExtensionResolverContext.
+func (w *DescriptorSetWatcher) FindExtensionByNumberContext(
+	ctx context.Context,
+	message protoreflect.FullName,
+	field protoreflect.FieldNumber,
+) (protoreflect.ExtensionType, error) {
+	return w.snapshot(ctx).types.FindExtensionByNumber(message, field)
+}
+
+// RangeExtensionsByMessageContext implements ExtensionResolverContext.
+func (w *DescriptorSetWatcher) RangeExtensionsByMessageContext(
+	ctx context.Context,
+	message protoreflect.FullName,
+	f func(protoreflect.ExtensionType) bool,
+) {
+	w.snapshot(ctx).types.RangeExtensionsByMessage(message, f)
+}
+
+// snapshot returns the schema pinned to the context's reflection stream.
+func (w *DescriptorSetWatcher) snapshot(ctx context.Context) *descriptorSetSchema {
+	schema, _ := pinToSession(ctx, w, func() any {
+		return w.current.Load()
+	}).(*descriptorSetSchema)
+	return schema
+}
+
+func (w *DescriptorSetWatcher) watch() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			if err := w.reloadIfChanged(); err != nil && w.onError != nil {
+				w.onError(err)
+			}
+		}
+	}
+}
+
+func (w *DescriptorSetWatcher) reloadIfChanged() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	stamp, err := statDescriptorSets(w.path)
+	if err != nil {
+		return err
+	}
+	if stamp == w.stamp {
+		return nil
+	}
+	return w.loadLocked(stamp)
+}
+
+// loadLocked loads the watcher's files, which had the given stamp before
+// loading started. Even if loading fails, the stamp is recorded so that we
+// don't retry until the files change again.
+func (w *DescriptorSetWatcher) loadLocked(stamp string) error {
+	w.stamp = stamp
+	schema, err := loadDescriptorSets(w.path)
+	if err != nil {
+		return err
+	}
+	w.current.Store(schema)
+	return nil
+}
+
+// descriptorSetPaths returns the paths of the descriptor sets to load from
+// path, which may be a file or a directory.
+func descriptorSetPaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		paths = append(paths, filepath.Join(path, entry.Name()))
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no descriptor sets in directory %s", path)
+	}
+	return paths, nil
+}
+
+// statDescriptorSets returns a string that changes whenever the descriptor
+// sets at path are modified, added, or removed.
+func statDescriptorSets(path string) (string, error) {
+	paths, err := descriptorSetPaths(path)
+	if err != nil {
+		return "", err
+	}
+	var stamp strings.Builder
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&stamp, "%s:%d:%d\n", path, info.Size(), info.ModTime().UnixNano())
+	}
+	return stamp.String(), nil
+}
+
+// loadDescriptorSets reads, merges, and links the descriptor sets at path.
+func loadDescriptorSets(path string) (*descriptorSetSchema, error) {
+	paths, err := descriptorSetPaths(path)
+	if err != nil {
+		return nil, err
+	}
+	merged := &descriptorpb.FileDescriptorSet{}
+	sources := make(map[string]string)
+	byName := make(map[string]*descriptorpb.FileDescriptorProto)
+	// A file is only treated as an import if every set that includes it says
+	// it's an import.
+	imports := make(map[string]struct{})
+	notImports := make(map[string]struct{})
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		fileSet, setImports, err := unmarshalDescriptorSet(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, fileProto := range fileSet.GetFile() {
+			name := fileProto.GetName()
+			if _, ok := setImports[name]; ok {
+				imports[name] = struct{}{}
+			} else {
+				notImports[name] = struct{}{}
+			}
+			// We've already read the Buf image metadata, and it shouldn't
+			// affect comparisons.
+			fileProto.ProtoReflect().SetUnknown(nil)
+			if source, ok := sources[name]; ok {
+				if !proto.Equal(byName[name], fileProto) {
+					return nil, fmt.Errorf("%q differs in descriptor sets %s and %s", name, source, path)
+				}
+				continue
+			}
+			sources[name] = path
+			byName[name] = fileProto
+			merged.File = append(merged.File, fileProto)
+		}
+	}
+	for name := range notImports {
+		delete(imports, name)
+	}
+	schema, err := newDescriptorSetSchema(merged, imports)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return schema, nil
+}
+
+type watchIntervalOption struct {
+	interval time.Duration
+}
+
+func (o *watchIntervalOption) apply(watcher *DescriptorSetWatcher) {
+	watcher.interval = o.interval
+}
+
+type watchErrorHandlerOption struct {
+	handler func(error)
+}
+
+func (o *watchErrorHandlerOption) apply(watcher *DescriptorSetWatcher) {
+	watcher.onError = o.handler
+}
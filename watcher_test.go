@@ -0,0 +1,246 @@
+// Copyright 2022-2025 The Connect Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grpcreflect
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"connectrpc.com/connect"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestDescriptorSetWatcher(t *testing.T) {
+	t.Parallel()
+	// The first schema only has the reflecttest files, and the second adds
+	// acme/v1/describe.proto.
+	full := newTestFileSet(t)
+	initial := &descriptorpb.FileDescriptorSet{File: full.GetFile()[:2]}
+	writeSet := func(t *testing.T, path string, fileSet *descriptorpb.FileDescriptorSet) {
+		t.Helper()
+		data, err := proto.Marshal(fileSet)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		// Write atomically, as the watcher's docs recommend.
+		tmp := filepath.Join(filepath.Dir(path), ".tmp")
+		if err := os.WriteFile(tmp, data, 0o600); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	}
+	newStream := func(t *testing.T, watcher *DescriptorSetWatcher) *ClientStream {
+		t.Helper()
+		return newTestStream(t, watcher.NewReflector())
+	}
+	initialNames := []string{"connect.reflecttest.v1.TestService"}
+	fullNames := []string{"acme.v1.DescribeService", "connect.reflecttest.v1.TestService"}
+
+	t.Run("snapshot_per_stream", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "schema.binpb")
+		writeSet(t, path, initial)
+		watcher, err := NewDescriptorSetWatcher(path, WithWatchInterval(0))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		t.Cleanup(watcher.Close)
+		client := newTestClient(t, func(mux *http.ServeMux) {
+			mux.Handle(NewHandlerV1(watcher.NewReflector()))
+		})
+		oldStream := client.NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = oldStream.Close()
+		})
+		names, err := oldStream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		checkNames(t, initialNames, names)
+
+		writeSet(t, path, full)
+		if err := watcher.Reload(); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		if names := watcher.Names(); !reflect.DeepEqual(fullNames, names) {
+			t.Fatalf("unexpected names after reload: want %v ; got %v", fullNames, names)
+		}
+		// The existing stream keeps using the old schema...
+		names, err = oldStream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		checkNames(t, initialNames, names)
+		_, err = oldStream.FileContainingSymbol("acme.v1.DescribeService")
+		if connect.CodeOf(err) != connect.CodeNotFound {
+			t.Fatalf("expected not found, got %v", err)
+		}
+		// ...but new streams see the new one.
+		newStream := client.NewStream(t.Context())
+		t.Cleanup(func() {
+			_, _ = newStream.Close()
+		})
+		names, err = newStream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		checkNames(t, fullNames, names)
+		if _, err := newStream.FileContainingSymbol("acme.v1.DescribeService"); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+	})
+	t.Run("invalid_reload", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "schema.binpb")
+		writeSet(t, path, initial)
+		watcher, err := NewDescriptorSetWatcher(path, WithWatchInterval(0))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		t.Cleanup(watcher.Close)
+		describe, _ := proto.Clone(full.GetFile()[2]).(*descriptorpb.FileDescriptorProto)
+		describe.Dependency = []string{"acme/v1/gone.proto"}
+		writeSet(t, path, &descriptorpb.FileDescriptorSet{
+			File: []*descriptorpb.FileDescriptorProto{describe},
+		})
+		if err := watcher.Reload(); err == nil || !strings.Contains(err.Error(), "acme/v1/gone.proto") {
+			t.Fatalf("expected error naming missing import, got %v", err)
+		}
+		stream := newStream(t, watcher)
+		names, err := stream.ListServices()
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		checkNames(t, initialNames, names)
+	})
+	t.Run("polling", func(t *testing.T) {
+		t.Parallel()
+		path := filepath.Join(t.TempDir(), "schema.binpb")
+		writeSet(t, path, initial)
+		errs := make(chan error, 1)
+		watcher, err := NewDescriptorSetWatcher(
+			path,
+			WithWatchInterval(time.Millisecond),
+			WithWatchErrorHandler(func(err error) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}),
+		)
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		t.Cleanup(watcher.Close)
+		writeSet(t, path, full)
+		waitForNames(t, watcher, fullNames)
+
+		if err := os.WriteFile(path, []byte("\xff\xff\xff"), 0o600); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		select {
+		case err := <-errs:
+			if !strings.Contains(err.Error(), "invalid descriptor set") {
+				t.Fatalf("unexpected err: %v", err)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for reload error")
+		}
+		if names := watcher.Names(); !reflect.DeepEqual(fullNames, names) {
+			t.Fatalf("expected previous schema to be kept: want %v ; got %v", fullNames, names)
+		}
+		watcher.Close()
+		writeSet(t, path, initial)
+		time.Sleep(10 * time.Millisecond)
+		if names := watcher.Names(); !reflect.DeepEqual(fullNames, names) {
+			t.Fatalf("expected schema not to change after Close: want %v ; got %v", fullNames, names)
+		}
+	})
+	t.Run("directory", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		// Both sets include reflecttest.proto, which is fine as long as
+		// they agree on its contents.
+		writeSet(t, filepath.Join(dir, "reflecttest.binpb"), initial)
+		describe := &descriptorpb.FileDescriptorSet{
+			File: []*descriptorpb.FileDescriptorProto{full.GetFile()[0], full.GetFile()[2]},
+		}
+		writeSet(t, filepath.Join(dir, "describe.binpb"), describe)
+		if err := os.WriteFile(filepath.Join(dir, ".ignored"), []byte("not a descriptor set"), 0o600); err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		watcher, err := NewDescriptorSetWatcher(dir, WithWatchInterval(0))
+		if err != nil {
+			t.Fatalf("unexpected err: %v", err)
+		}
+		t.Cleanup(watcher.Close)
+		if names := watcher.Names(); !reflect.DeepEqual(fullNames, names) {
+			t.Fatalf("unexpected names: want %v ; got %v", fullNames, names)
+		}
+
+		conflicting, _ := proto.Clone(full.GetFile()[0]).(*descriptorpb.FileDescriptorProto)
+		conflicting.Options = &descriptorpb.FileOptions{GoPackage: proto.String("example.com/other")}
+		writeSet(t, filepath.Join(dir, "describe.binpb"), &descriptorpb.FileDescriptorSet{
+			File: []*descriptorpb.FileDescriptorProto{conflicting, full.GetFile()[2]},
+		})
+		err = watcher.Reload()
+		if err == nil || !strings.Contains(err.Error(), `"connect/reflecttest/v1/reflecttest.proto" differs`) {
+			t.Fatalf("expected conflict error, got %v", err)
+		}
+	})
+	t.Run("missing", func(t *testing.T) {
+		t.Parallel()
+		_, err := NewDescriptorSetWatcher(filepath.Join(t.TempDir(), "missing.binpb"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Fatalf("expected not exist error, got %v", err)
+		}
+		_, err = NewDescriptorSetWatcher(t.TempDir())
+		if err == nil {
+			t.Fatal("expected error for empty directory")
+		}
+	})
+}
+
+func checkNames(t *testing.T, expected []string, names []protoreflect.FullName) {
+	t.Helper()
+	got := make([]string, len(names))
+	for i, name := range names {
+		got[i] = string(name)
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Fatalf("unexpected service names: want %v ; got %v", expected, names)
+	}
+}
+
+func waitForNames(t *testing.T, watcher *DescriptorSetWatcher, expected []string) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !reflect.DeepEqual(expected, watcher.Names()) {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for names %v ; got %v", expected, watcher.Names())
+		}
+		time.Sleep(time.Millisecond)
+	}
+}